@@ -2,16 +2,51 @@ package umbrella
 
 import "net/http"
 
-// AllowAccept is middleware that allows a request only if any of
-// the specified strings is included in the Accept header.
+// AllowAccept is middleware that allows a request only if the Accept header
+// accepts any of the specified media types, such as "application/json" or
+// "text/*". Wildcards, parameters and quality values of the Accept header
+// are honoured, and a request without the header accepts any type.
 // Returns 406 Not Acceptable status if the request has a type that is not allowed.
+// It panics if a media type is invalid.
 func AllowAccept(contentTypes ...string) func(http.Handler) http.Handler {
-	return AllowHTTPHeader(http.StatusNotAcceptable, "Accept", contentTypes...)
+	list := mustParseMediaRanges(contentTypes)
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			accept := parseAccept(r.Header.Get("Accept"))
+			for _, t := range list {
+				if mediaTypeQuality(accept, t) > 0 {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+			w.WriteHeader(http.StatusNotAcceptable)
+		}
+		return http.HandlerFunc(fn)
+	}
 }
 
-// DisallowAccept is middleware that disallow a request only if any of
-// the specified strings is included in the Accept header.
+// DisallowAccept is middleware that disallows a request only if the Accept
+// header explicitly accepts any of the specified media types. Ranges
+// excluded with q=0 and "*/*" are not regarded as explicit.
 // Returns 406 Not Acceptable status if the request has a type that is not allowed.
+// It panics if a media type is invalid.
 func DisallowAccept(contentTypes ...string) func(http.Handler) http.Handler {
-	return DisallowHTTPHeader(http.StatusNotAcceptable, "Accept", contentTypes...)
+	list := mustParseMediaRanges(contentTypes)
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			for _, m := range parseAccept(r.Header.Get("Accept")) {
+				if m.q == 0 || m.typ == "*" {
+					continue
+				}
+				for _, t := range list {
+					if m.overlaps(t) {
+						w.WriteHeader(http.StatusNotAcceptable)
+						return
+					}
+				}
+			}
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
 }
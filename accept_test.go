@@ -2,6 +2,7 @@ package umbrella
 
 import (
 	"net/http"
+	"net/http/httptest"
 	"testing"
 )
 
@@ -62,3 +63,46 @@ func TestDisallowAccept(t *testing.T) {
 		_ = resp.Body.Close()
 	})
 }
+
+func TestAcceptMediaTypes(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	allows := AllowAccept("application/json", "text/*")
+	disallows := DisallowAccept("text/html", "image/*")
+
+	testCases := []struct {
+		accept    string
+		allows    int
+		disallows int
+	}{
+		{accept: "", allows: http.StatusOK, disallows: http.StatusOK},
+		{accept: "*/*", allows: http.StatusOK, disallows: http.StatusOK},
+		{accept: "application/jsonp-evil", allows: http.StatusNotAcceptable, disallows: http.StatusOK},
+		{accept: "application/*", allows: http.StatusOK, disallows: http.StatusOK},
+		{accept: "Application/JSON; charset=utf-8", allows: http.StatusNotAcceptable, disallows: http.StatusOK},
+		{accept: "text/csv;q=0.5", allows: http.StatusOK, disallows: http.StatusOK},
+		{accept: "application/json;q=0, text/*;q=0, */*", allows: http.StatusNotAcceptable, disallows: http.StatusOK},
+		{accept: "application/json;q=0, */*;q=0.1", allows: http.StatusOK, disallows: http.StatusOK},
+		{accept: "text/html,application/xhtml+xml,*/*;q=0.8", allows: http.StatusOK, disallows: http.StatusNotAcceptable},
+		{accept: "text/html;q=0, application/json", allows: http.StatusOK, disallows: http.StatusOK},
+		{accept: "image/png", allows: http.StatusNotAcceptable, disallows: http.StatusNotAcceptable},
+		{accept: "invalid, application/json;q=2", allows: http.StatusNotAcceptable, disallows: http.StatusOK},
+	}
+	for _, tc := range testCases {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tc.accept != "" {
+			r.Header.Set("Accept", tc.accept)
+		}
+		w := httptest.NewRecorder()
+		allows(handler).ServeHTTP(w, r)
+		if got, want := w.Code, tc.allows; got != want {
+			t.Errorf("AllowAccept %q: got: %v, want: %v", tc.accept, got, want)
+		}
+		w = httptest.NewRecorder()
+		disallows(handler).ServeHTTP(w, r)
+		if got, want := w.Code, tc.disallows; got != want {
+			t.Errorf("DisallowAccept %q: got: %v, want: %v", tc.accept, got, want)
+		}
+	}
+}
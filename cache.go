@@ -0,0 +1,465 @@
+package umbrella
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// heuristicallyCacheable is the set of status codes that can be stored
+// without explicit freshness information (RFC 9110 15.1).
+var heuristicallyCacheable = map[int]bool{
+	http.StatusOK:                   true,
+	http.StatusNonAuthoritativeInfo: true,
+	http.StatusNoContent:            true,
+	http.StatusMultipleChoices:      true,
+	http.StatusMovedPermanently:     true,
+	http.StatusPermanentRedirect:    true,
+	http.StatusNotFound:             true,
+	http.StatusMethodNotAllowed:     true,
+	http.StatusGone:                 true,
+	http.StatusRequestURITooLong:    true,
+	http.StatusNotImplemented:       true,
+}
+
+// sharedCacheStaleTTL is how long a stale response with a validator is
+// kept in the store so that it can be revalidated.
+const sharedCacheStaleTTL = time.Hour
+
+// SharedCache provides a shared cache middleware that follows the caching
+// rules of RFC 9111.
+// The freshness of a response is derived from the s-maxage and max-age
+// directives of Cache-Control or from the Expires header, and responses
+// marked no-store or private are never stored. Responses served from the
+// cache carry an Age header, and stale responses that have an ETag or
+// Last-Modified validator are revalidated with a conditional request to
+// the handler. Like Stampede, concurrent requests for the same resource
+// execute the handler only once.
+// Stale responses are served while they are refreshed in the background,
+// or when the handler returns a 5xx status or panics, during the periods
+// given by the stale-while-revalidate and stale-if-error directives of the
+// response (RFC 5861), or by the WithStaleWhileRevalidate and
+// WithStaleIfError options if the directives are absent. Responses marked
+// must-revalidate, proxy-revalidate, no-cache or s-maxage are never served
+// stale.
+// The storage can be replaced with the WithCacheStore option.
+func SharedCache(opts ...CacheOption) func(http.Handler) http.Handler {
+	mw, _ := NewSharedCache(opts...)
+	return mw
+}
+
+// NewSharedCache creates a SharedCache middleware and returns it together
+// with a CacheController that removes responses from its cache.
+func NewSharedCache(opts ...CacheOption) (func(http.Handler) http.Handler, *CacheController) {
+	c := &sharedCache{opts: newCacheOptions(opts...)}
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				next.ServeHTTP(w, r)
+				// Unsafe methods invalidate the stored response.
+				if !isSafeMethod(r.Method) {
+					c.delete(r.URL.String())
+				}
+				return
+			}
+			c.serveHTTP(next, w, r)
+		}
+		return http.HandlerFunc(fn)
+	}, NewCacheController(c.opts.store)
+}
+
+type sharedCache struct {
+	opts       *cacheOptions
+	group      singleflight.Group
+	refreshing sync.Map
+}
+
+type sharedCacheResult struct {
+	res    *CachedResponse
+	stored bool
+	panic  interface{}
+}
+
+func (c *sharedCache) serveHTTP(next http.Handler, w http.ResponseWriter, r *http.Request) {
+	directives := parseCacheControl(r.Header)
+	if _, ok := directives["no-store"]; ok {
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	key := r.URL.String()
+	var stored *CachedResponse
+	if res, err := c.opts.store.Get(key); err == nil {
+		if matchVary(res, r) {
+			stored = res
+		}
+	} else if !errors.Is(err, ErrCacheMiss) {
+		log.Printf("sharedcache.error: %v", err)
+	}
+	now := time.Now()
+	if stored != nil && isFresh(stored, now, directives) {
+		serveSharedCache(w, r, stored, true)
+		return
+	}
+	var staleness time.Duration
+	if stored != nil {
+		staleness = currentAge(stored, now) - freshnessLifetime(stored)
+		if c.canServeStale(stored, staleness, directives, "stale-while-revalidate") {
+			// Only one goroutine refreshes the response in the background.
+			if _, loaded := c.refreshing.LoadOrStore(key, struct{}{}); !loaded {
+				go c.refresh(next, r.Clone(context.Background()), stored)
+			}
+			serveSharedCache(w, r, stored, true)
+			return
+		}
+	}
+
+	executed := false
+	v, _, _ := c.group.Do(key, func() (interface{}, error) {
+		executed = true
+		return c.fetch(next, r, stored), nil
+	})
+	result := v.(*sharedCacheResult)
+	// A response that was not stored or that was selected for other request
+	// headers cannot be shared, so the handler is executed again.
+	if !executed && !result.failed() && (!result.stored || !matchVary(result.res, r)) {
+		result = c.fetch(next, r, stored)
+	}
+	if result.failed() {
+		if stored != nil && c.canServeStale(stored, staleness, directives, "stale-if-error") {
+			serveSharedCache(w, r, stored, true)
+			return
+		}
+		if result.panic != nil {
+			panic(result.panic)
+		}
+	}
+	serveSharedCache(w, r, result.res, false)
+}
+
+func (c *sharedCache) refresh(next http.Handler, r *http.Request, stale *CachedResponse) {
+	key := r.URL.String()
+	defer c.refreshing.Delete(key)
+	v, _, _ := c.group.Do(key, func() (interface{}, error) {
+		return c.fetch(next, r, stale), nil
+	})
+	if result := v.(*sharedCacheResult); result.panic != nil {
+		log.Printf("sharedcache.error: %v", result.panic)
+	}
+}
+
+// staleWindow returns how long after expiration the response can be served
+// stale under the directive, which is stale-while-revalidate or
+// stale-if-error.
+func (c *sharedCache) staleWindow(res *CachedResponse, requestDirectives map[string]string, name string) time.Duration {
+	if hasDirective(requestDirectives, "no-cache", "max-age", "min-fresh") {
+		return 0
+	}
+	directives := parseCacheControl(res.Header)
+	if hasDirective(directives, "must-revalidate", "proxy-revalidate", "no-cache", "s-maxage") {
+		return 0
+	}
+	if v, ok := directives[name]; ok {
+		d, _ := parseDeltaSeconds(v)
+		return d
+	}
+	if name == "stale-while-revalidate" {
+		return c.opts.staleWhileRevalidate
+	}
+	return c.opts.staleIfError
+}
+
+// canServeStale reports whether the response, which expired staleness ago,
+// can be served under the directive.
+func (c *sharedCache) canServeStale(res *CachedResponse, staleness time.Duration, requestDirectives map[string]string, name string) bool {
+	window := c.staleWindow(res, requestDirectives, name)
+	return window > 0 && staleness < window
+}
+
+func (result *sharedCacheResult) failed() bool {
+	return result.panic != nil || result.res.StatusCode >= http.StatusInternalServerError
+}
+
+// fetch executes the handler and stores the response if possible.
+// If stale is not nil, the request is made conditional on its validators.
+func (c *sharedCache) fetch(next http.Handler, r *http.Request, stale *CachedResponse) (result *sharedCacheResult) {
+	defer func() {
+		if p := recover(); p != nil {
+			result = &sharedCacheResult{panic: p}
+		}
+	}()
+	req := r.Clone(r.Context())
+	req.Method = http.MethodGet
+	req.Header.Del("If-None-Match")
+	req.Header.Del("If-Modified-Since")
+	if stale != nil {
+		if etag := stale.Header.Get("ETag"); etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		if lastModified := stale.Header.Get("Last-Modified"); lastModified != "" {
+			req.Header.Set("If-Modified-Since", lastModified)
+		}
+	}
+
+	// Use ResponseRecorder to record the results.
+	rec := httptest.NewRecorder()
+	requestTime := time.Now()
+	next.ServeHTTP(rec, req)
+	responseTime := time.Now()
+
+	var res *CachedResponse
+	tags := extractCacheTags(rec.Header())
+	if stale != nil && rec.Code == http.StatusNotModified {
+		res = updateCachedResponse(stale, rec.Header(), requestTime, responseTime)
+		if len(tags) != 0 {
+			res.Tags = tags
+		}
+	} else {
+		res = &CachedResponse{
+			Tags:         tags,
+			StatusCode:   rec.Code,
+			Header:       rec.Header(),
+			Body:         rec.Body.Bytes(),
+			Vary:         make(map[string]string),
+			RequestTime:  requestTime,
+			ResponseTime: responseTime,
+		}
+		if res.Header.Get("Date") == "" {
+			res.Header.Set("Date", responseTime.UTC().Format(http.TimeFormat))
+		}
+		for _, name := range varyNames(res) {
+			res.Vary[name] = strings.Join(req.Header.Values(name), ",")
+		}
+	}
+
+	key := r.URL.String()
+	result = &sharedCacheResult{res: res}
+	if stale != nil && result.failed() {
+		// A failed response does not replace the stored one, which can
+		// still be served with stale-if-error.
+		return result
+	}
+	window := c.staleWindow(res, nil, "stale-while-revalidate")
+	if d := c.staleWindow(res, nil, "stale-if-error"); d > window {
+		window = d
+	}
+	if ttl, ok := sharedCacheTTL(res, req, window); ok {
+		if err := c.opts.store.Set(key, res, ttl); err != nil {
+			log.Printf("sharedcache.error: %v", err)
+		} else {
+			result.stored = true
+		}
+	} else {
+		c.delete(key)
+	}
+	return result
+}
+
+func (c *sharedCache) delete(key string) {
+	if err := c.opts.store.Delete(key); err != nil {
+		log.Printf("sharedcache.error: %v", err)
+	}
+}
+
+// serveSharedCache writes the response, adding the Age header if it was
+// served from the cache.
+func serveSharedCache(w http.ResponseWriter, r *http.Request, res *CachedResponse, hit bool) {
+	for k, v := range res.Header {
+		w.Header()[k] = v
+	}
+	if hit {
+		w.Header().Set("Age", strconv.FormatInt(int64(currentAge(res, time.Now())/time.Second), 10))
+	} else {
+		w.Header().Del("Age")
+	}
+	if res.StatusCode == http.StatusOK {
+		if code := CheckPreconditions(r, res.Header.Get("ETag"), lastModifiedTime(res.Header)); code != 0 {
+			w.WriteHeader(code)
+			return
+		}
+	}
+	w.WriteHeader(res.StatusCode)
+	if r.Method != http.MethodHead {
+		_, _ = w.Write(res.Body)
+	}
+}
+
+// updateCachedResponse returns a copy of the response with the headers of
+// a 304 Not Modified response applied.
+func updateCachedResponse(res *CachedResponse, header http.Header, requestTime, responseTime time.Time) *CachedResponse {
+	res2 := *res
+	res2.Header = res.Header.Clone()
+	for k, v := range header {
+		if k != "Content-Length" {
+			res2.Header[k] = v
+		}
+	}
+	if header.Get("Date") == "" {
+		res2.Header.Set("Date", responseTime.UTC().Format(http.TimeFormat))
+	}
+	res2.RequestTime = requestTime
+	res2.ResponseTime = responseTime
+	return &res2
+}
+
+func varyNames(res *CachedResponse) []string {
+	var names []string
+	for _, line := range res.Header.Values("Vary") {
+		for _, name := range splitHeaderList(line, ',') {
+			names = append(names, http.CanonicalHeaderKey(name))
+		}
+	}
+	return names
+}
+
+// matchVary reports whether the response was selected with the same
+// request headers as r.
+func matchVary(res *CachedResponse, r *http.Request) bool {
+	for name, value := range res.Vary {
+		if strings.Join(r.Header.Values(name), ",") != value {
+			return false
+		}
+	}
+	return true
+}
+
+// sharedCacheTTL reports whether a shared cache is allowed to store the
+// response and how long it should be kept, including the period during
+// which it can be served stale.
+func sharedCacheTTL(res *CachedResponse, r *http.Request, stale time.Duration) (time.Duration, bool) {
+	if res.StatusCode < 200 || res.StatusCode == http.StatusPartialContent || res.StatusCode == http.StatusNotModified {
+		return 0, false
+	}
+	directives := parseCacheControl(res.Header)
+	if hasDirective(directives, "no-store", "private") {
+		return 0, false
+	}
+	if r.Header.Get("Authorization") != "" &&
+		!hasDirective(directives, "public", "s-maxage", "must-revalidate") {
+		return 0, false
+	}
+	for _, name := range varyNames(res) {
+		if name == "*" {
+			return 0, false
+		}
+	}
+	if !hasDirective(directives, "public", "max-age", "s-maxage") &&
+		res.Header.Get("Expires") == "" && !heuristicallyCacheable[res.StatusCode] {
+		return 0, false
+	}
+	ttl := freshnessLifetime(res) - currentAge(res, time.Now())
+	if res.Header.Get("ETag") != "" || res.Header.Get("Last-Modified") != "" {
+		if ttl < 0 {
+			ttl = 0
+		}
+		if stale < sharedCacheStaleTTL {
+			stale = sharedCacheStaleTTL
+		}
+		return ttl + stale, true
+	}
+	ttl += stale
+	return ttl, ttl > 0
+}
+
+// isFresh reports whether the response can be served without revalidation.
+func isFresh(res *CachedResponse, now time.Time, requestDirectives map[string]string) bool {
+	if hasDirective(parseCacheControl(res.Header), "no-cache") || hasDirective(requestDirectives, "no-cache") {
+		return false
+	}
+	age := currentAge(res, now)
+	lifetime := freshnessLifetime(res)
+	if v, ok := requestDirectives["max-age"]; ok {
+		if d, ok := parseDeltaSeconds(v); !ok || age > d {
+			return false
+		}
+	}
+	if v, ok := requestDirectives["min-fresh"]; ok {
+		if d, ok := parseDeltaSeconds(v); !ok || lifetime-age < d {
+			return false
+		}
+	}
+	return lifetime > age
+}
+
+// freshnessLifetime calculates the freshness lifetime (RFC 9111 4.2.1).
+func freshnessLifetime(res *CachedResponse) time.Duration {
+	directives := parseCacheControl(res.Header)
+	for _, name := range []string{"s-maxage", "max-age"} {
+		if v, ok := directives[name]; ok {
+			d, _ := parseDeltaSeconds(v)
+			return d
+		}
+	}
+	date := responseDate(res)
+	if v := res.Header.Get("Expires"); v != "" {
+		expires, err := http.ParseTime(v)
+		if err != nil || expires.Before(date) {
+			return 0
+		}
+		return expires.Sub(date)
+	}
+	// Heuristic freshness is 10% of the time since the last modification.
+	if lastModified, err := http.ParseTime(res.Header.Get("Last-Modified")); err == nil && heuristicallyCacheable[res.StatusCode] {
+		d := date.Sub(lastModified) / 10
+		if d < 0 {
+			return 0
+		}
+		if d > 24*time.Hour {
+			return 24 * time.Hour
+		}
+		return d
+	}
+	return 0
+}
+
+// currentAge calculates the current age of the response (RFC 9111 4.2.3).
+func currentAge(res *CachedResponse, now time.Time) time.Duration {
+	apparentAge := res.ResponseTime.Sub(responseDate(res))
+	if apparentAge < 0 {
+		apparentAge = 0
+	}
+	ageValue, _ := parseDeltaSeconds(res.Header.Get("Age"))
+	correctedAgeValue := ageValue + res.ResponseTime.Sub(res.RequestTime)
+	correctedInitialAge := apparentAge
+	if correctedAgeValue > correctedInitialAge {
+		correctedInitialAge = correctedAgeValue
+	}
+	return correctedInitialAge + now.Sub(res.ResponseTime)
+}
+
+func responseDate(res *CachedResponse) time.Time {
+	date, err := http.ParseTime(res.Header.Get("Date"))
+	if err != nil {
+		return res.ResponseTime
+	}
+	return date
+}
+
+func hasDirective(directives map[string]string, names ...string) bool {
+	for _, name := range names {
+		if _, ok := directives[name]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// parseDeltaSeconds parses the delta-seconds of a header value.
+func parseDeltaSeconds(v string) (time.Duration, bool) {
+	n, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	if n > int64(^uint64(0)>>1)/int64(time.Second) {
+		n = int64(^uint64(0)>>1) / int64(time.Second)
+	}
+	return time.Duration(n) * time.Second, true
+}
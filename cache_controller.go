@@ -0,0 +1,122 @@
+package umbrella
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// CacheController provides features for removing responses cached by the
+// cache middleware.
+// Keys are the request URIs of the cached requests, such as "/search?q=a".
+type CacheController struct {
+	store CacheStore
+}
+
+// NewCacheController creates and returns a new CacheController that
+// operates on the store.
+func NewCacheController(store CacheStore) *CacheController {
+	return &CacheController{store: store}
+}
+
+// Purge removes the response cached for the key.
+func (c *CacheController) Purge(key string) error {
+	return c.store.Delete(key)
+}
+
+// PurgePrefix removes all responses whose key starts with prefix and
+// returns the number of removed responses.
+func (c *CacheController) PurgePrefix(prefix string) (int, error) {
+	return c.purge(func(key string, _ *CachedResponse) bool {
+		return strings.HasPrefix(key, prefix)
+	})
+}
+
+// PurgeTag removes all responses tagged with tag and returns the number of
+// removed responses. Handlers tag responses with the Cache-Tag header
+// (comma-separated) or the Surrogate-Key header (space-separated).
+func (c *CacheController) PurgeTag(tag string) (int, error) {
+	return c.purge(func(_ string, res *CachedResponse) bool {
+		for _, t := range res.Tags {
+			if t == tag {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+func (c *CacheController) purge(match func(key string, res *CachedResponse) bool) (int, error) {
+	var keys []string
+	err := c.store.Range(func(key string, res *CachedResponse) bool {
+		if match(key, res) {
+			keys = append(keys, key)
+		}
+		return true
+	})
+	if err != nil {
+		return 0, err
+	}
+	for i, key := range keys {
+		if err := c.store.Delete(key); err != nil {
+			return i, err
+		}
+	}
+	return len(keys), nil
+}
+
+// Handler purges responses with the key, prefix or tag parameter and returns
+// the number of removed responses in JSON.
+// It accepts POST and DELETE requests. It does not authenticate requests,
+// so it must be mounted behind authentication.
+func (c *CacheController) Handler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		w.Header().Set("Allow", "POST, DELETE")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	var n int
+	var err error
+	switch {
+	case r.FormValue("key") != "":
+		key := r.FormValue("key")
+		if _, err = c.store.Get(key); err == nil {
+			if err = c.Purge(key); err == nil {
+				n = 1
+			}
+		} else if errors.Is(err, ErrCacheMiss) {
+			err = nil
+		}
+	case r.FormValue("prefix") != "":
+		n, err = c.PurgePrefix(r.FormValue("prefix"))
+	case r.FormValue("tag") != "":
+		n, err = c.PurgeTag(r.FormValue("tag"))
+	default:
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	raw, _ := json.Marshal(map[string]int{"purged": n})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(raw)
+}
+
+// extractCacheTags removes the Cache-Tag and Surrogate-Key headers from the
+// header and returns the tags they contain.
+func extractCacheTags(header http.Header) []string {
+	var tags []string
+	for _, v := range header.Values("Cache-Tag") {
+		tags = append(tags, splitHeaderList(v, ',')...)
+	}
+	for _, v := range header.Values("Surrogate-Key") {
+		tags = append(tags, strings.Fields(v)...)
+	}
+	header.Del("Cache-Tag")
+	header.Del("Surrogate-Key")
+	return tags
+}
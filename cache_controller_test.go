@@ -0,0 +1,134 @@
+package umbrella
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCacheController(t *testing.T) {
+	newHandler := func(calls map[string]int) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls[r.URL.Path]++
+			w.Header().Set("Cache-Control", "max-age=60")
+			if strings.HasPrefix(r.URL.Path, "/articles/") {
+				w.Header().Set("Cache-Tag", "articles, "+r.URL.Path)
+			} else {
+				w.Header().Set("Surrogate-Key", "pages top")
+			}
+			w.WriteHeader(http.StatusOK)
+		})
+	}
+	get := func(h http.Handler, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+	paths := []string{"/articles/1", "/articles/2", "/about"}
+
+	diskStore, err := NewDiskCacheStore(t.TempDir(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	middlewares := map[string]func() (func(http.Handler) http.Handler, *CacheController){
+		"stampede": func() (func(http.Handler) http.Handler, *CacheController) {
+			return NewStampede(time.Minute)
+		},
+		"shared": func() (func(http.Handler) http.Handler, *CacheController) {
+			return NewSharedCache()
+		},
+		"disk": func() (func(http.Handler) http.Handler, *CacheController) {
+			return NewStampede(time.Minute, WithCacheStore(diskStore))
+		},
+	}
+	for name, newMiddleware := range middlewares {
+		t.Run("case="+name, func(t *testing.T) {
+			calls := make(map[string]int)
+			mw, c := newMiddleware()
+			h := mw(newHandler(calls))
+			fill := func() {
+				for _, path := range paths {
+					w := get(h, path)
+					if w.Header().Get("Cache-Tag") != "" || w.Header().Get("Surrogate-Key") != "" {
+						t.Errorf("tag headers must be removed: %v", w.Header())
+					}
+				}
+			}
+			fill()
+
+			if err := c.Purge("/about"); err != nil {
+				t.Fatal(err)
+			}
+			fill()
+			if got, want := calls["/about"], 2; got != want {
+				t.Errorf("got: %v, want: %v", got, want)
+			}
+
+			if n, err := c.PurgeTag("articles"); err != nil || n != 2 {
+				t.Errorf("got: %v, %v, want: 2", n, err)
+			}
+			fill()
+			if got, want := calls["/articles/1"], 2; got != want {
+				t.Errorf("got: %v, want: %v", got, want)
+			}
+
+			if n, err := c.PurgePrefix("/articles/2"); err != nil || n != 1 {
+				t.Errorf("got: %v, %v, want: 1", n, err)
+			}
+			fill()
+			if got, want := calls["/articles/2"], 3; got != want {
+				t.Errorf("got: %v, want: %v", got, want)
+			}
+			if got, want := calls["/articles/1"], 2; got != want {
+				t.Errorf("got: %v, want: %v", got, want)
+			}
+
+			if n, err := c.PurgeTag("top"); err != nil || n != 1 {
+				t.Errorf("got: %v, %v, want: 1", n, err)
+			}
+			fill()
+			if got, want := calls["/about"], 3; got != want {
+				t.Errorf("got: %v, want: %v", got, want)
+			}
+		})
+	}
+
+	t.Run("case=handler", func(t *testing.T) {
+		calls := make(map[string]int)
+		mw, c := NewStampede(time.Minute)
+		h := mw(newHandler(calls))
+		for _, path := range paths {
+			get(h, path)
+		}
+
+		testCases := []struct {
+			method string
+			form   url.Values
+			code   int
+			body   string
+		}{
+			{method: http.MethodGet, form: url.Values{"tag": {"articles"}}, code: http.StatusMethodNotAllowed},
+			{method: http.MethodPost, form: url.Values{}, code: http.StatusBadRequest},
+			{method: http.MethodPost, form: url.Values{"tag": {"articles"}}, code: http.StatusOK, body: `{"purged":2}`},
+			{method: http.MethodPost, form: url.Values{"key": {"/about"}}, code: http.StatusOK, body: `{"purged":1}`},
+			{method: http.MethodPost, form: url.Values{"key": {"/about"}}, code: http.StatusOK, body: `{"purged":0}`},
+			{method: http.MethodDelete, form: url.Values{"prefix": {"/"}}, code: http.StatusOK, body: `{"purged":0}`},
+		}
+		for _, tc := range testCases {
+			r := httptest.NewRequest(tc.method, "/purge?"+tc.form.Encode(), nil)
+			w := httptest.NewRecorder()
+			c.Handler(w, r)
+			if got, want := w.Code, tc.code; got != want {
+				t.Errorf("got: %v, want: %v", got, want)
+			}
+			if tc.body != "" {
+				if got, want := w.Body.String(), tc.body; got != want {
+					t.Errorf("got: %v, want: %v", got, want)
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,56 @@
+package umbrella
+
+import "time"
+
+// CacheOption ...
+type CacheOption func(o *cacheOptions)
+
+type cacheOptions struct {
+	store                CacheStore
+	staleWhileRevalidate time.Duration
+	staleIfError         time.Duration
+}
+
+func newCacheOptions(opts ...CacheOption) *cacheOptions {
+	o := new(cacheOptions)
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.store == nil {
+		o.store = NewMemoryCacheStore()
+	}
+	return o
+}
+
+// WithCacheStore sets the CacheStore in which responses are stored.
+// By default, each middleware uses its own MemoryCacheStore.
+func WithCacheStore(store CacheStore) CacheOption {
+	return func(o *cacheOptions) {
+		if store != nil {
+			o.store = store
+		}
+	}
+}
+
+// WithStaleWhileRevalidate sets the period after expiration during which the
+// stale response is served while a single background request refreshes it.
+// SharedCache uses it for responses without the stale-while-revalidate
+// directive.
+func WithStaleWhileRevalidate(d time.Duration) CacheOption {
+	return func(o *cacheOptions) {
+		if d > 0 {
+			o.staleWhileRevalidate = d
+		}
+	}
+}
+
+// WithStaleIfError sets the period after expiration during which the stale
+// response is served if the handler returns a 5xx status or panics.
+// SharedCache uses it for responses without the stale-if-error directive.
+func WithStaleIfError(d time.Duration) CacheOption {
+	return func(o *cacheOptions) {
+		if d > 0 {
+			o.staleIfError = d
+		}
+	}
+}
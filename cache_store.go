@@ -0,0 +1,125 @@
+package umbrella
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrCacheMiss is returned by CacheStore.Get when the key is not stored
+// or has expired.
+var ErrCacheMiss = errors.New("umbrella: cache miss")
+
+// CachedResponse is a response stored in a CacheStore.
+// A CachedResponse returned by a CacheStore must not be modified.
+type CachedResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	// Expires is the time until which Stampede serves the response as fresh.
+	Expires time.Time
+	// Vary holds the values of the request headers nominated by the Vary
+	// header of the response.
+	Vary map[string]string
+	// RequestTime and ResponseTime are the times when the handler was
+	// called and when it returned.
+	RequestTime  time.Time
+	ResponseTime time.Time
+	// Tags holds the tags given by the Cache-Tag and Surrogate-Key headers.
+	Tags []string
+}
+
+// CacheStore is the storage used by the cache middleware.
+// Implementations must be safe for concurrent use.
+type CacheStore interface {
+	// Get returns the response stored for the key or ErrCacheMiss.
+	Get(key string) (*CachedResponse, error)
+	// Set stores the response for the key. If ttl is greater than zero,
+	// the response expires after that duration.
+	Set(key string, res *CachedResponse, ttl time.Duration) error
+	// Delete removes the response stored for the key.
+	Delete(key string) error
+	// Range calls f for each stored response until f returns false.
+	Range(f func(key string, res *CachedResponse) bool) error
+}
+
+// MemoryCacheStore is a CacheStore that keeps responses in memory.
+type MemoryCacheStore struct {
+	rwm     sync.RWMutex
+	entries map[string]memoryCacheEntry
+}
+
+type memoryCacheEntry struct {
+	res     *CachedResponse
+	expires time.Time
+}
+
+// NewMemoryCacheStore creates and returns a new MemoryCacheStore.
+func NewMemoryCacheStore() *MemoryCacheStore {
+	return &MemoryCacheStore{entries: make(map[string]memoryCacheEntry)}
+}
+
+// Get implements the CacheStore.
+func (s *MemoryCacheStore) Get(key string) (*CachedResponse, error) {
+	s.rwm.RLock()
+	e, ok := s.entries[key]
+	s.rwm.RUnlock()
+	if !ok {
+		return nil, ErrCacheMiss
+	}
+	if !e.expires.IsZero() && !e.expires.After(time.Now()) {
+		s.deleteEntry(key, e)
+		return nil, ErrCacheMiss
+	}
+	return e.res, nil
+}
+
+// deleteEntry removes the entry stored for the key unless it has been
+// replaced by Set since it was read.
+func (s *MemoryCacheStore) deleteEntry(key string, e memoryCacheEntry) {
+	s.rwm.Lock()
+	if cur, ok := s.entries[key]; ok && cur == e {
+		delete(s.entries, key)
+	}
+	s.rwm.Unlock()
+}
+
+// Set implements the CacheStore.
+func (s *MemoryCacheStore) Set(key string, res *CachedResponse, ttl time.Duration) error {
+	e := memoryCacheEntry{res: res}
+	if ttl > 0 {
+		e.expires = time.Now().Add(ttl)
+	}
+	s.rwm.Lock()
+	s.entries[key] = e
+	s.rwm.Unlock()
+	return nil
+}
+
+// Delete implements the CacheStore.
+func (s *MemoryCacheStore) Delete(key string) error {
+	s.rwm.Lock()
+	delete(s.entries, key)
+	s.rwm.Unlock()
+	return nil
+}
+
+// Range implements the CacheStore.
+func (s *MemoryCacheStore) Range(f func(key string, res *CachedResponse) bool) error {
+	now := time.Now()
+	s.rwm.RLock()
+	entries := make(map[string]*CachedResponse, len(s.entries))
+	for key, e := range s.entries {
+		if e.expires.IsZero() || e.expires.After(now) {
+			entries[key] = e.res
+		}
+	}
+	s.rwm.RUnlock()
+	for key, res := range entries {
+		if !f(key, res) {
+			break
+		}
+	}
+	return nil
+}
@@ -0,0 +1,300 @@
+package umbrella
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	diskCacheFileExt = ".cache"
+	diskCacheTempExt = ".tmp"
+)
+
+// ErrCacheTooLarge is returned by DiskCacheStore.Set when a response is
+// larger than the size limit of the store.
+var ErrCacheTooLarge = errors.New("umbrella: cached response is too large")
+
+// DiskCacheStore is a CacheStore that keeps responses in files under a
+// directory, so they survive restarts without being held in memory.
+// Files are written atomically, and the least recently used responses are
+// removed when the total size exceeds the limit.
+type DiskCacheStore struct {
+	dir      string
+	maxBytes int64
+
+	mu   sync.Mutex
+	size int64
+}
+
+type diskCacheFile struct {
+	Key      string
+	Response *CachedResponse
+}
+
+// NewDiskCacheStore creates and returns a new DiskCacheStore that stores
+// files in dir. If maxBytes is greater than zero, the total size of the
+// files is limited to maxBytes. Files left from a previous run are reused
+// and expired ones are removed.
+func NewDiskCacheStore(dir string, maxBytes int64) (*DiskCacheStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	s := &DiskCacheStore{dir: dir, maxBytes: maxBytes}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for _, entry := range entries {
+		name := filepath.Join(dir, entry.Name())
+		switch filepath.Ext(name) {
+		case diskCacheTempExt:
+			// Remove files from interrupted writes.
+			_ = os.Remove(name)
+		case diskCacheFileExt:
+			expires, err := readDiskCacheExpires(name)
+			if err != nil || (!expires.IsZero() && !expires.After(now)) {
+				_ = os.Remove(name)
+				continue
+			}
+			if info, err := entry.Info(); err == nil {
+				s.size += info.Size()
+			}
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.evict("")
+	return s, nil
+}
+
+// Get implements the CacheStore.
+func (s *DiskCacheStore) Get(key string) (*CachedResponse, error) {
+	name := s.filename(key)
+	f, err := s.read(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrCacheMiss
+	}
+	if err != nil {
+		return nil, err
+	}
+	if f == nil || f.Key != key {
+		_ = s.removeStale(name, key)
+		return nil, ErrCacheMiss
+	}
+	// Update the modification time to record the use of the file.
+	now := time.Now()
+	_ = os.Chtimes(name, now, now)
+	return f.Response, nil
+}
+
+// Set implements the CacheStore.
+func (s *DiskCacheStore) Set(key string, res *CachedResponse, ttl time.Duration) error {
+	var buf bytes.Buffer
+	var expires int64
+	if ttl > 0 {
+		expires = time.Now().Add(ttl).UnixNano()
+	}
+	_ = binary.Write(&buf, binary.BigEndian, expires)
+	if err := gob.NewEncoder(&buf).Encode(&diskCacheFile{Key: key, Response: res}); err != nil {
+		return err
+	}
+	size := int64(buf.Len())
+	if s.maxBytes > 0 && size > s.maxBytes {
+		return ErrCacheTooLarge
+	}
+
+	// Write to a temporary file and rename it so that readers never see
+	// a partially written file.
+	f, err := os.CreateTemp(s.dir, "*"+diskCacheTempExt)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+
+	name := s.filename(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var oldSize int64
+	if info, err := os.Stat(name); err == nil {
+		oldSize = info.Size()
+	}
+	if err := os.Rename(f.Name(), name); err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+	s.size += size - oldSize
+	s.evict(name)
+	return nil
+}
+
+// Delete implements the CacheStore.
+func (s *DiskCacheStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.remove(s.filename(key))
+}
+
+// Range implements the CacheStore.
+func (s *DiskCacheStore) Range(f func(key string, res *CachedResponse) bool) error {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), diskCacheFileExt) {
+			continue
+		}
+		file, err := s.read(filepath.Join(s.dir, entry.Name()))
+		if errors.Is(err, os.ErrNotExist) || (err == nil && file == nil) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if !f(file.Key, file.Response) {
+			break
+		}
+	}
+	return nil
+}
+
+// read decodes the file. It returns nil if the file is expired or broken.
+func (s *DiskCacheStore) read(name string) (*diskCacheFile, error) {
+	raw, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) < 8 {
+		return nil, nil
+	}
+	if expires := decodeDiskCacheExpires(raw); !expires.IsZero() && !expires.After(time.Now()) {
+		return nil, nil
+	}
+	var f diskCacheFile
+	if err := gob.NewDecoder(bytes.NewReader(raw[8:])).Decode(&f); err != nil {
+		return nil, nil
+	}
+	return &f, nil
+}
+
+// removeStale deletes the file if it is still expired, broken or stores
+// another key, so that a file written by Set since it was read is kept.
+func (s *DiskCacheStore) removeStale(name, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := s.read(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if f != nil && f.Key == key {
+		return nil
+	}
+	return s.remove(name)
+}
+
+// remove deletes the file and updates the total size.
+// The caller must hold s.mu.
+func (s *DiskCacheStore) remove(name string) error {
+	info, err := os.Stat(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(name); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	s.size -= info.Size()
+	return nil
+}
+
+// evict removes the least recently used files, except keep, until the total
+// size is within the limit. The caller must hold s.mu.
+func (s *DiskCacheStore) evict(keep string) {
+	if s.maxBytes <= 0 || s.size <= s.maxBytes {
+		return
+	}
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return
+	}
+	type file struct {
+		name    string
+		modTime time.Time
+	}
+	files := make([]file, 0, len(entries))
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), diskCacheFileExt) {
+			continue
+		}
+		if info, err := entry.Info(); err == nil {
+			files = append(files, file{name: filepath.Join(s.dir, entry.Name()), modTime: info.ModTime()})
+		}
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].modTime.Before(files[j].modTime)
+	})
+	for _, f := range files {
+		if s.size <= s.maxBytes {
+			return
+		}
+		if f.name != keep {
+			_ = s.remove(f.name)
+		}
+	}
+}
+
+func (s *DiskCacheStore) filename(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:])+diskCacheFileExt)
+}
+
+func readDiskCacheExpires(name string) (time.Time, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer f.Close()
+	raw := make([]byte, 8)
+	if _, err := io.ReadFull(f, raw); err != nil {
+		return time.Time{}, err
+	}
+	return decodeDiskCacheExpires(raw), nil
+}
+
+func decodeDiskCacheExpires(raw []byte) time.Time {
+	n := int64(binary.BigEndian.Uint64(raw[:8]))
+	if n == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, n)
+}
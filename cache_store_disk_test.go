@@ -0,0 +1,162 @@
+package umbrella
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDiskCacheStore(t *testing.T) {
+	t.Run("case=persist", func(t *testing.T) {
+		dir := t.TempDir()
+		s, err := NewDiskCacheStore(dir, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res := &CachedResponse{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"text/plain"}},
+			Body:       []byte("body"),
+			Expires:    time.Now().Add(time.Minute).Round(0),
+		}
+		if err := s.Set("/a", res, time.Minute); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Set("/b", res, time.Millisecond); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(time.Millisecond * 5)
+
+		// A new store reads the files written by the previous one.
+		s, err = NewDiskCacheStore(dir, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := s.Get("/a")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got.Body, res.Body) || got.Header.Get("Content-Type") != "text/plain" || !got.Expires.Equal(res.Expires) {
+			t.Errorf("got: %#v, want: %#v", got, res)
+		}
+		if _, err := s.Get("/b"); !errors.Is(err, ErrCacheMiss) {
+			t.Errorf("got: %v, want: %v", err, ErrCacheMiss)
+		}
+		if err := s.Delete("/a"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.Get("/a"); !errors.Is(err, ErrCacheMiss) {
+			t.Errorf("got: %v, want: %v", err, ErrCacheMiss)
+		}
+		files, _ := filepath.Glob(filepath.Join(dir, "*"))
+		if got, want := len(files), 0; got != want {
+			t.Errorf("got: %v, want: %v", got, want)
+		}
+	})
+
+	t.Run("case=replaced", func(t *testing.T) {
+		s, err := NewDiskCacheStore(t.TempDir(), 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res := &CachedResponse{StatusCode: http.StatusOK, Body: []byte("body")}
+		if err := s.Set("/a", res, time.Millisecond); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(time.Millisecond * 5)
+		name := s.filename("/a")
+		if f, err := s.read(name); err != nil || f != nil {
+			t.Fatalf("got: %v, %v, want: expired", f, err)
+		}
+		// Set runs between the read of the expired file and its removal.
+		if err := s.Set("/a", res, time.Minute); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.removeStale(name, "/a"); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := s.Get("/a"); err != nil || !bytes.Equal(got.Body, res.Body) {
+			t.Errorf("got: %v, %v, want: %v", got, err, res)
+		}
+		if err := s.Set("/b", res, time.Millisecond); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(time.Millisecond * 5)
+		if err := s.removeStale(s.filename("/b"), "/b"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := os.Stat(s.filename("/b")); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("got: %v, want: %v", err, os.ErrNotExist)
+		}
+	})
+
+	t.Run("case=limit", func(t *testing.T) {
+		dir := t.TempDir()
+		s, err := NewDiskCacheStore(dir, 1024)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res := &CachedResponse{StatusCode: http.StatusOK, Body: bytes.Repeat([]byte("a"), 300)}
+		for i := 0; i < 5; i++ {
+			if err := s.Set(fmt.Sprintf("/%d", i), res, 0); err != nil {
+				t.Fatal(err)
+			}
+			time.Sleep(time.Millisecond * 10)
+		}
+		var size int64
+		files, _ := filepath.Glob(filepath.Join(dir, "*"))
+		for _, name := range files {
+			info, _ := os.Stat(name)
+			size += info.Size()
+		}
+		if size > 1024 {
+			t.Errorf("got: %v, want: <= 1024", size)
+		}
+		// The oldest responses are removed first.
+		if _, err := s.Get("/0"); !errors.Is(err, ErrCacheMiss) {
+			t.Errorf("got: %v, want: %v", err, ErrCacheMiss)
+		}
+		if _, err := s.Get("/4"); err != nil {
+			t.Errorf("got: %v, want: nil", err)
+		}
+
+		large := &CachedResponse{StatusCode: http.StatusOK, Body: bytes.Repeat([]byte("a"), 2048)}
+		if err := s.Set("/large", large, 0); !errors.Is(err, ErrCacheTooLarge) {
+			t.Errorf("got: %v, want: %v", err, ErrCacheTooLarge)
+		}
+	})
+
+	t.Run("case=stampede", func(t *testing.T) {
+		s, err := NewDiskCacheStore(t.TempDir(), 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		calls := 0
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			w.WriteHeader(http.StatusOK)
+			fmt.Fprintf(w, "%d", calls)
+		})
+
+		teardown := setup(Stampede(time.Second*5, WithCacheStore(s))(handler))
+		defer teardown()
+
+		for i := 0; i < 2; i++ {
+			resp, err := httpClient.Get(httpServer.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			raw, _ := io.ReadAll(resp.Body)
+			_ = resp.Body.Close()
+			if got, want := string(raw), "1"; got != want {
+				t.Errorf("got: %v, want: %v", got, want)
+			}
+		}
+	})
+}
@@ -0,0 +1,53 @@
+package umbrella
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestMemoryCacheStore(t *testing.T) {
+	s := NewMemoryCacheStore()
+	res := &CachedResponse{StatusCode: http.StatusOK, Body: []byte("body")}
+
+	if _, err := s.Get("/a"); !errors.Is(err, ErrCacheMiss) {
+		t.Errorf("got: %v, want: %v", err, ErrCacheMiss)
+	}
+	if err := s.Set("/a", res, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := s.Get("/a"); err != nil || got != res {
+		t.Errorf("got: %v, %v, want: %v", got, err, res)
+	}
+	if err := s.Delete("/a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get("/a"); !errors.Is(err, ErrCacheMiss) {
+		t.Errorf("got: %v, want: %v", err, ErrCacheMiss)
+	}
+
+	if err := s.Set("/b", res, time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond * 5)
+	if _, err := s.Get("/b"); !errors.Is(err, ErrCacheMiss) {
+		t.Errorf("got: %v, want: %v", err, ErrCacheMiss)
+	}
+
+	// An expired entry replaced by Set after it was read is kept.
+	if err := s.Set("/c", res, time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	s.rwm.RLock()
+	expired := s.entries["/c"]
+	s.rwm.RUnlock()
+	time.Sleep(time.Millisecond * 5)
+	if err := s.Set("/c", res, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	s.deleteEntry("/c", expired)
+	if got, err := s.Get("/c"); err != nil || got != res {
+		t.Errorf("got: %v, %v, want: %v", got, err, res)
+	}
+}
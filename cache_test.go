@@ -0,0 +1,253 @@
+package umbrella
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSharedCache(t *testing.T) {
+	do := func(h http.Handler, method string, header http.Header) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, "/resource", nil)
+		for k, v := range header {
+			r.Header[k] = v
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	testCases := []struct {
+		name   string
+		code   int
+		header http.Header
+		calls  int
+	}{
+		{name: "max-age", code: http.StatusOK, header: http.Header{"Cache-Control": {"max-age=60"}}, calls: 1},
+		{name: "s-maxage", code: http.StatusOK, header: http.Header{"Cache-Control": {"max-age=0, s-maxage=60"}}, calls: 1},
+		{name: "expires", code: http.StatusOK, header: http.Header{"Expires": {time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)}}, calls: 1},
+		{name: "no-store", code: http.StatusOK, header: http.Header{"Cache-Control": {"no-store, max-age=60"}}, calls: 2},
+		{name: "private", code: http.StatusOK, header: http.Header{"Cache-Control": {"private, max-age=60"}}, calls: 2},
+		{name: "no-cache", code: http.StatusOK, header: http.Header{"Cache-Control": {"no-cache, max-age=60"}}, calls: 2},
+		{name: "age", code: http.StatusOK, header: http.Header{"Cache-Control": {"max-age=60"}, "Age": {"120"}}, calls: 2},
+		{name: "error", code: http.StatusInternalServerError, header: http.Header{}, calls: 2},
+		{name: "vary=*", code: http.StatusOK, header: http.Header{"Cache-Control": {"max-age=60"}, "Vary": {"*"}}, calls: 2},
+	}
+	for _, tc := range testCases {
+		t.Run("case="+tc.name, func(t *testing.T) {
+			calls := 0
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				for k, v := range tc.header {
+					w.Header()[k] = v
+				}
+				w.WriteHeader(tc.code)
+				fmt.Fprintf(w, "%d", calls)
+			})
+			h := SharedCache()(handler)
+			for i := 0; i < 2; i++ {
+				if got, want := do(h, http.MethodGet, nil).Code, tc.code; got != want {
+					t.Errorf("got: %v, want: %v", got, want)
+				}
+			}
+			if got, want := calls, tc.calls; got != want {
+				t.Errorf("got: %v, want: %v", got, want)
+			}
+		})
+	}
+
+	t.Run("case=hit", func(t *testing.T) {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.Header().Set("ETag", `"v1"`)
+			w.WriteHeader(http.StatusOK)
+			fmt.Fprint(w, "body")
+		})
+		h := SharedCache()(handler)
+		if got := do(h, http.MethodGet, nil).Header().Get("Age"); got != "" {
+			t.Errorf("got: %v, want: empty", got)
+		}
+		w := do(h, http.MethodGet, nil)
+		if got, want := w.Header().Get("Age"), "0"; got != want {
+			t.Errorf("got: %v, want: %v", got, want)
+		}
+		if got, want := w.Body.String(), "body"; got != want {
+			t.Errorf("got: %v, want: %v", got, want)
+		}
+		w = do(h, http.MethodHead, nil)
+		if got, want := w.Body.Len(), 0; got != want {
+			t.Errorf("got: %v, want: %v", got, want)
+		}
+		w = do(h, http.MethodGet, http.Header{"If-None-Match": {`"v0", W/"v1"`}})
+		if got, want := w.Code, http.StatusNotModified; got != want {
+			t.Errorf("got: %v, want: %v", got, want)
+		}
+	})
+
+	t.Run("case=revalidate", func(t *testing.T) {
+		calls := 0
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			w.Header().Set("Cache-Control", "max-age=0")
+			w.Header().Set("ETag", `"v1"`)
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+			fmt.Fprint(w, "body")
+		})
+		h := SharedCache()(handler)
+		for i := 0; i < 3; i++ {
+			w := do(h, http.MethodGet, nil)
+			if got, want := w.Code, http.StatusOK; got != want {
+				t.Errorf("got: %v, want: %v", got, want)
+			}
+			if got, want := w.Body.String(), "body"; got != want {
+				t.Errorf("got: %v, want: %v", got, want)
+			}
+		}
+		if got, want := calls, 3; got != want {
+			t.Errorf("got: %v, want: %v", got, want)
+		}
+	})
+
+	t.Run("case=vary", func(t *testing.T) {
+		calls := 0
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.Header().Set("Vary", "Accept-Language")
+			w.WriteHeader(http.StatusOK)
+			fmt.Fprint(w, r.Header.Get("Accept-Language"))
+		})
+		h := SharedCache()(handler)
+		for _, lang := range []string{"en", "en", "ja", "ja", "en"} {
+			w := do(h, http.MethodGet, http.Header{"Accept-Language": {lang}})
+			if got, want := w.Body.String(), lang; got != want {
+				t.Errorf("got: %v, want: %v", got, want)
+			}
+		}
+		if got, want := calls, 3; got != want {
+			t.Errorf("got: %v, want: %v", got, want)
+		}
+	})
+
+	t.Run("case=authorization", func(t *testing.T) {
+		calls := 0
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.WriteHeader(http.StatusOK)
+		})
+		h := SharedCache()(handler)
+		for i := 0; i < 2; i++ {
+			do(h, http.MethodGet, http.Header{"Authorization": {"Bearer token"}})
+		}
+		if got, want := calls, 2; got != want {
+			t.Errorf("got: %v, want: %v", got, want)
+		}
+	})
+
+	t.Run("case=invalidate", func(t *testing.T) {
+		calls := 0
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.WriteHeader(http.StatusOK)
+		})
+		h := SharedCache()(handler)
+		do(h, http.MethodGet, nil)
+		do(h, http.MethodPost, nil)
+		do(h, http.MethodGet, nil)
+		if got, want := calls, 3; got != want {
+			t.Errorf("got: %v, want: %v", got, want)
+		}
+	})
+
+	t.Run("case=request-no-cache", func(t *testing.T) {
+		calls := 0
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.WriteHeader(http.StatusOK)
+		})
+		h := SharedCache()(handler)
+		do(h, http.MethodGet, nil)
+		do(h, http.MethodGet, http.Header{"Cache-Control": {"no-cache"}})
+		do(h, http.MethodGet, http.Header{"Cache-Control": {"max-age=0"}})
+		if got, want := calls, 3; got != want {
+			t.Errorf("got: %v, want: %v", got, want)
+		}
+	})
+
+	t.Run("case=stale-while-revalidate", func(t *testing.T) {
+		var calls int32
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			n := atomic.AddInt32(&calls, 1)
+			w.Header().Set("Cache-Control", "max-age=0")
+			w.WriteHeader(http.StatusOK)
+			fmt.Fprintf(w, "%d", n)
+		})
+		h := SharedCache(WithStaleWhileRevalidate(time.Minute))(handler)
+		do(h, http.MethodGet, nil)
+		if got, want := do(h, http.MethodGet, nil).Body.String(), "1"; got != want {
+			t.Errorf("got: %v, want: %v", got, want)
+		}
+		for i := 0; i < 100 && atomic.LoadInt32(&calls) < 2; i++ {
+			time.Sleep(time.Millisecond * 10)
+		}
+		if got, want := atomic.LoadInt32(&calls), int32(2); got != want {
+			t.Errorf("got: %v, want: %v", got, want)
+		}
+	})
+
+	t.Run("case=stale-if-error", func(t *testing.T) {
+		testCases := []struct {
+			name         string
+			opts         []CacheOption
+			cacheControl string
+			panic        bool
+			code         int
+		}{
+			{name: "option", opts: []CacheOption{WithStaleIfError(time.Minute)}, cacheControl: "max-age=0", code: http.StatusOK},
+			{name: "directive", cacheControl: "max-age=0, stale-if-error=60", code: http.StatusOK},
+			{name: "panic", opts: []CacheOption{WithStaleIfError(time.Minute)}, cacheControl: "max-age=0", panic: true, code: http.StatusOK},
+			{name: "must-revalidate", opts: []CacheOption{WithStaleIfError(time.Minute)}, cacheControl: "max-age=0, must-revalidate", code: http.StatusInternalServerError},
+			{name: "none", cacheControl: "max-age=0", code: http.StatusInternalServerError},
+		}
+		for _, tc := range testCases {
+			t.Run("case="+tc.name, func(t *testing.T) {
+				calls := 0
+				handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					calls++
+					w.Header().Set("Cache-Control", tc.cacheControl)
+					w.Header().Set("ETag", `"v1"`)
+					if calls > 1 {
+						if tc.panic {
+							panic("failed")
+						}
+						w.WriteHeader(http.StatusInternalServerError)
+						return
+					}
+					w.WriteHeader(http.StatusOK)
+					fmt.Fprint(w, "body")
+				})
+				h := SharedCache(tc.opts...)(handler)
+				do(h, http.MethodGet, nil)
+				w := do(h, http.MethodGet, nil)
+				if got, want := w.Code, tc.code; got != want {
+					t.Errorf("got: %v, want: %v", got, want)
+				}
+				if tc.code == http.StatusOK {
+					if got, want := w.Body.String(), "body"; got != want {
+						t.Errorf("got: %v, want: %v", got, want)
+					}
+				}
+			})
+		}
+	})
+}
@@ -33,3 +33,20 @@ func NoCacheHeaderFunc() HeaderFunc {
 		"max-age=0", "proxy-revalidate", "s-maxage=0",
 	)
 }
+
+// parseCacheControl returns the directives of the Cache-Control header as
+// a map of lowercase directive names to their unquoted arguments.
+func parseCacheControl(header http.Header) map[string]string {
+	directives := make(map[string]string)
+	for _, line := range header.Values("Cache-Control") {
+		for _, directive := range splitHeaderList(line, ',') {
+			name, value := directive, ""
+			if i := strings.IndexByte(directive, '='); i >= 0 {
+				name = directive[:i]
+				value = unquoteHeaderValue(strings.TrimSpace(directive[i+1:]))
+			}
+			directives[strings.ToLower(strings.TrimSpace(name))] = value
+		}
+	}
+	return directives
+}
@@ -0,0 +1,63 @@
+package umbrella
+
+import (
+	"context"
+	"net"
+	"net/http"
+)
+
+// ClientInfo describes the client of a request.
+// It is stored in the request context by RealIP and RealIPWithConfig.
+type ClientInfo struct {
+	// IP is the address of the client.
+	IP net.IP
+	// PeerIP is the address of the connection, which is the nearest proxy
+	// if the request was forwarded.
+	PeerIP net.IP
+	// Scheme and Host are the scheme and host of the request made by the
+	// client.
+	Scheme string
+	Host   string
+	// Proxies holds the addresses of the trusted proxies the request passed
+	// through, from the nearest to the farthest.
+	Proxies []net.IP
+}
+
+type clientInfoKey struct{}
+
+// GetClientInfo returns the ClientInfo of the request. If no ClientInfo is
+// stored in the request context, it is created from the connection.
+// The returned value must not be modified.
+func GetClientInfo(r *http.Request) *ClientInfo {
+	if info, ok := r.Context().Value(clientInfoKey{}).(*ClientInfo); ok {
+		return info
+	}
+	return newClientInfo(r)
+}
+
+func withClientInfo(ctx context.Context, info *ClientInfo) context.Context {
+	return context.WithValue(ctx, clientInfoKey{}, info)
+}
+
+// newClientInfo creates a ClientInfo from the connection of the request.
+func newClientInfo(r *http.Request) *ClientInfo {
+	info := &ClientInfo{
+		IP:     remoteIP(r),
+		Scheme: "http",
+		Host:   r.Host,
+	}
+	info.PeerIP = info.IP
+	if r.TLS != nil {
+		info.Scheme = "https"
+	}
+	return info
+}
+
+// clientIP returns the address of the client as a string, or an empty string
+// if it is unknown.
+func clientIP(r *http.Request) string {
+	if ip := GetClientInfo(r).IP; ip != nil {
+		return ip.String()
+	}
+	return ""
+}
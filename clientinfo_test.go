@@ -0,0 +1,56 @@
+package umbrella
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestGetClientInfo(t *testing.T) {
+	t.Run("case=connection", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "https://example.com/", nil)
+		r.RemoteAddr = "192.0.2.1:1234"
+		r.TLS = &tls.ConnectionState{}
+		want := &ClientInfo{
+			IP:     net.ParseIP("192.0.2.1"),
+			PeerIP: net.ParseIP("192.0.2.1"),
+			Scheme: "https",
+			Host:   "example.com",
+		}
+		if got := GetClientInfo(r); !reflect.DeepEqual(got, want) {
+			t.Errorf("got: %#v, want: %#v", got, want)
+		}
+	})
+
+	t.Run("case=RealIPWithConfig", func(t *testing.T) {
+		proxies, _ := ParseCIDRs("10.0.0.0/8")
+		var got *ClientInfo
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = GetClientInfo(r)
+		})
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = "10.0.0.1:1234"
+		r.Header.Set("Forwarded", "for=192.0.2.1;proto=https;host=example.com, for=10.0.0.3, for=10.0.0.2")
+		RealIPWithConfig(RealIPConfig{TrustedProxies: proxies, Headers: []string{"Forwarded"}})(handler).ServeHTTP(httptest.NewRecorder(), r)
+		want := &ClientInfo{
+			IP:     net.ParseIP("192.0.2.1"),
+			PeerIP: net.ParseIP("10.0.0.1"),
+			Scheme: "https",
+			Host:   "example.com",
+			Proxies: []net.IP{
+				net.ParseIP("10.0.0.1"),
+				net.ParseIP("10.0.0.2"),
+				net.ParseIP("10.0.0.3"),
+			},
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got: %#v, want: %#v", got, want)
+		}
+		if got, want := r.RemoteAddr, "10.0.0.1:1234"; got != want {
+			t.Errorf("got: %v, want: %v", got, want)
+		}
+	})
+}
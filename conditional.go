@@ -0,0 +1,95 @@
+package umbrella
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// CheckPreconditions evaluates the If-Match, If-Unmodified-Since,
+// If-None-Match and If-Modified-Since headers of the request against the
+// current representation in the order defined by RFC 9110 13.2.2.
+// etag and lastModified are the validators of the current representation;
+// pass an empty etag or a zero lastModified if they are not available, and
+// both if the representation does not exist.
+// It returns 0 if the request can be processed, 304 Not Modified for a GET
+// or HEAD request whose representation has not changed, or 412
+// Precondition Failed if a precondition is false.
+func CheckPreconditions(r *http.Request, etag string, lastModified time.Time) int {
+	exists := etag != "" || !lastModified.IsZero()
+	lastModified = lastModified.Truncate(time.Second)
+
+	if v := r.Header.Get("If-Match"); v != "" {
+		if !matchETagList(v, etag, exists, strongETagMatch) {
+			return http.StatusPreconditionFailed
+		}
+	} else if since, err := http.ParseTime(r.Header.Get("If-Unmodified-Since")); err == nil && !lastModified.IsZero() {
+		if lastModified.After(since) {
+			return http.StatusPreconditionFailed
+		}
+	}
+
+	safe := r.Method == http.MethodGet || r.Method == http.MethodHead
+	if v := r.Header.Get("If-None-Match"); v != "" {
+		if matchETagList(v, etag, exists, weakETagMatch) {
+			if safe {
+				return http.StatusNotModified
+			}
+			return http.StatusPreconditionFailed
+		}
+	} else if since, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil && safe && !lastModified.IsZero() {
+		if !lastModified.After(since) {
+			return http.StatusNotModified
+		}
+	}
+	return 0
+}
+
+// matchETagList reports whether the list of entity-tags in an If-Match or
+// If-None-Match header matches etag.
+func matchETagList(list, etag string, exists bool, match func(a, b string) bool) bool {
+	for _, tag := range splitHeaderList(list, ',') {
+		if tag == "*" {
+			if exists {
+				return true
+			}
+			continue
+		}
+		if etag != "" && match(tag, etag) {
+			return true
+		}
+	}
+	return false
+}
+
+// strongETagMatch reports whether both entity-tags are strong and equal.
+func strongETagMatch(a, b string) bool {
+	return !strings.HasPrefix(a, "W/") && !strings.HasPrefix(b, "W/") && a == b
+}
+
+// weakETagMatch reports whether the entity-tags are equal, ignoring the
+// weakness indicator.
+func weakETagMatch(a, b string) bool {
+	return strings.TrimPrefix(a, "W/") == strings.TrimPrefix(b, "W/")
+}
+
+// lastModifiedTime returns the time in the Last-Modified header or the
+// zero time.
+func lastModifiedTime(header http.Header) time.Time {
+	t, err := http.ParseTime(header.Get("Last-Modified"))
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// writePreconditionStatus writes the 304 Not Modified or 412 Precondition
+// Failed response returned by CheckPreconditions.
+func writePreconditionStatus(w http.ResponseWriter, code int) {
+	w.Header().Del("Content-Length")
+	if code == http.StatusPreconditionFailed {
+		http.Error(w, http.StatusText(code), code)
+		return
+	}
+	w.WriteHeader(code)
+}
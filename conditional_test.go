@@ -0,0 +1,54 @@
+package umbrella
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCheckPreconditions(t *testing.T) {
+	lastModified := time.Date(2021, 5, 1, 0, 0, 0, 0, time.UTC)
+	before := lastModified.Add(-time.Hour).Format(http.TimeFormat)
+	after := lastModified.Add(time.Hour).Format(http.TimeFormat)
+
+	testCases := []struct {
+		name   string
+		method string
+		header http.Header
+		etag   string
+		want   int
+	}{
+		{name: "none", method: http.MethodGet, header: http.Header{}, etag: `"a"`, want: 0},
+		{name: "if-match", method: http.MethodPut, header: http.Header{"If-Match": {`"b", "a"`}}, etag: `"a"`, want: 0},
+		{name: "if-match-mismatch", method: http.MethodPut, header: http.Header{"If-Match": {`"b"`}}, etag: `"a"`, want: http.StatusPreconditionFailed},
+		{name: "if-match-weak", method: http.MethodPut, header: http.Header{"If-Match": {`W/"a"`}}, etag: `W/"a"`, want: http.StatusPreconditionFailed},
+		{name: "if-match-star", method: http.MethodPut, header: http.Header{"If-Match": {"*"}}, etag: `"a"`, want: 0},
+		{name: "if-match-star-missing", method: http.MethodPut, header: http.Header{"If-Match": {"*"}}, etag: "", want: http.StatusPreconditionFailed},
+		{name: "if-unmodified-since", method: http.MethodDelete, header: http.Header{"If-Unmodified-Since": {after}}, etag: `"a"`, want: 0},
+		{name: "if-unmodified-since-modified", method: http.MethodDelete, header: http.Header{"If-Unmodified-Since": {before}}, etag: `"a"`, want: http.StatusPreconditionFailed},
+		{name: "if-match-precedes-date", method: http.MethodDelete, header: http.Header{"If-Match": {`"a"`}, "If-Unmodified-Since": {before}}, etag: `"a"`, want: 0},
+		{name: "if-none-match", method: http.MethodGet, header: http.Header{"If-None-Match": {`"b", W/"a"`}}, etag: `"a"`, want: http.StatusNotModified},
+		{name: "if-none-match-head", method: http.MethodHead, header: http.Header{"If-None-Match": {`"a"`}}, etag: `W/"a"`, want: http.StatusNotModified},
+		{name: "if-none-match-mismatch", method: http.MethodGet, header: http.Header{"If-None-Match": {`"b"`}}, etag: `"a"`, want: 0},
+		{name: "if-none-match-unsafe", method: http.MethodPut, header: http.Header{"If-None-Match": {"*"}}, etag: `"a"`, want: http.StatusPreconditionFailed},
+		{name: "if-none-match-star-missing", method: http.MethodPut, header: http.Header{"If-None-Match": {"*"}}, etag: "", want: 0},
+		{name: "if-modified-since", method: http.MethodGet, header: http.Header{"If-Modified-Since": {after}}, etag: `"a"`, want: http.StatusNotModified},
+		{name: "if-modified-since-modified", method: http.MethodGet, header: http.Header{"If-Modified-Since": {before}}, etag: `"a"`, want: 0},
+		{name: "if-none-match-precedes-date", method: http.MethodGet, header: http.Header{"If-None-Match": {`"b"`}, "If-Modified-Since": {after}}, etag: `"a"`, want: 0},
+		{name: "if-modified-since-unsafe", method: http.MethodPost, header: http.Header{"If-Modified-Since": {after}}, etag: `"a"`, want: 0},
+	}
+	for _, tc := range testCases {
+		t.Run("case="+tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(tc.method, "/", nil)
+			r.Header = tc.header
+			lm := lastModified
+			if tc.etag == "" {
+				lm = time.Time{}
+			}
+			if got, want := CheckPreconditions(r, tc.etag, lm), tc.want; got != want {
+				t.Errorf("got: %v, want: %v", got, want)
+			}
+		})
+	}
+}
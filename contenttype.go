@@ -2,16 +2,54 @@ package umbrella
 
 import "net/http"
 
-// AllowContentType is middleware that allows a request only if any of
-// the specified strings is included in the Content-Type header.
+// AllowContentType is middleware that allows a request only if the
+// Content-Type header matches any of the specified media types, such as
+// "application/json", "text/*" or "text/plain; charset=utf-8".
+// Parameters of the specified media types must be present in the header.
 // Returns 415 Unsupported Media Type status if the request has a type that is not allowed.
+// It panics if a media type is invalid.
 func AllowContentType(contentTypes ...string) func(http.Handler) http.Handler {
-	return AllowHTTPHeader(http.StatusUnsupportedMediaType, "Content-Type", contentTypes...)
+	list := mustParseMediaRanges(contentTypes)
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if t, err := parseMediaRange(r.Header.Get("Content-Type")); err == nil {
+				for _, m := range list {
+					if m.contains(t) {
+						next.ServeHTTP(w, r)
+						return
+					}
+				}
+			}
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+		}
+		return http.HandlerFunc(fn)
+	}
 }
 
-// DisallowContentType is middleware that disallow a request only if any of
-// the specified strings is included in the Content-Type header.
+// DisallowContentType is middleware that disallows a request only if the
+// Content-Type header matches any of the specified media types.
+// Requests with a malformed Content-Type header are also disallowed.
 // Returns 415 Unsupported Media Type status if the request has a type that is not allowed.
+// It panics if a media type is invalid.
 func DisallowContentType(contentTypes ...string) func(http.Handler) http.Handler {
-	return DisallowHTTPHeader(http.StatusUnsupportedMediaType, "Content-Type", contentTypes...)
+	list := mustParseMediaRanges(contentTypes)
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if v := r.Header.Get("Content-Type"); v != "" {
+				t, err := parseMediaRange(v)
+				if err != nil {
+					w.WriteHeader(http.StatusUnsupportedMediaType)
+					return
+				}
+				for _, m := range list {
+					if m.contains(t) {
+						w.WriteHeader(http.StatusUnsupportedMediaType)
+						return
+					}
+				}
+			}
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
 }
@@ -2,6 +2,7 @@ package umbrella
 
 import (
 	"net/http"
+	"net/http/httptest"
 	"testing"
 )
 
@@ -67,3 +68,58 @@ func TestDisallowContentType(t *testing.T) {
 		_ = resp.Body.Close()
 	})
 }
+
+func TestContentTypeMediaTypes(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	allows := AllowContentType("application/json", "text/*; charset=utf-8")
+	disallows := DisallowContentType("text/html", "image/*")
+
+	testCases := []struct {
+		contentType string
+		allows      int
+		disallows   int
+	}{
+		{contentType: "", allows: http.StatusUnsupportedMediaType, disallows: http.StatusOK},
+		{contentType: "application/json", allows: http.StatusOK, disallows: http.StatusOK},
+		{contentType: "Application/JSON; charset=utf-8", allows: http.StatusOK, disallows: http.StatusOK},
+		{contentType: "application/jsonp-evil", allows: http.StatusUnsupportedMediaType, disallows: http.StatusOK},
+		{contentType: "application/vnd.api+json", allows: http.StatusUnsupportedMediaType, disallows: http.StatusOK},
+		{contentType: "text/plain; charset=UTF-8", allows: http.StatusOK, disallows: http.StatusOK},
+		{contentType: "text/plain; charset=iso-8859-1", allows: http.StatusUnsupportedMediaType, disallows: http.StatusOK},
+		{contentType: "text/plain", allows: http.StatusUnsupportedMediaType, disallows: http.StatusOK},
+		{contentType: "text/html; charset=utf-8", allows: http.StatusOK, disallows: http.StatusUnsupportedMediaType},
+		{contentType: "image/png", allows: http.StatusUnsupportedMediaType, disallows: http.StatusUnsupportedMediaType},
+		{contentType: "text/html;;", allows: http.StatusUnsupportedMediaType, disallows: http.StatusUnsupportedMediaType},
+	}
+	for _, tc := range testCases {
+		r := httptest.NewRequest(http.MethodPost, "/", nil)
+		if tc.contentType != "" {
+			r.Header.Set("Content-Type", tc.contentType)
+		}
+		w := httptest.NewRecorder()
+		allows(handler).ServeHTTP(w, r)
+		if got, want := w.Code, tc.allows; got != want {
+			t.Errorf("AllowContentType %q: got: %v, want: %v", tc.contentType, got, want)
+		}
+		w = httptest.NewRecorder()
+		disallows(handler).ServeHTTP(w, r)
+		if got, want := w.Code, tc.disallows; got != want {
+			t.Errorf("DisallowContentType %q: got: %v, want: %v", tc.contentType, got, want)
+		}
+	}
+}
+
+func TestContentTypeInvalid(t *testing.T) {
+	for _, v := range []string{"json", "*/json", "text/html;q=2"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%q: got: nil, want: panic", v)
+				}
+			}()
+			AllowContentType(v)
+		}()
+	}
+}
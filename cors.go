@@ -0,0 +1,209 @@
+package umbrella
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// CORSConfig is the configuration for CORS.
+type CORSConfig struct {
+	// AllowedOrigins is the list of origins that can make cross-origin
+	// requests. An entry is an exact origin such as "https://example.com",
+	// a wildcard subdomain such as "https://*.example.com", which does not
+	// match the parent domain, or "*", which allows any origin.
+	AllowedOrigins []string
+	// AllowOriginFunc reports whether an origin that is not listed in
+	// AllowedOrigins can make cross-origin requests.
+	AllowOriginFunc func(origin string) bool
+	// AllowedMethods is the list of methods allowed in preflight requests.
+	// The default is GET, HEAD and POST.
+	AllowedMethods []string
+	// AllowedHeaders is the list of request headers allowed in preflight
+	// requests, and "*" allows any header. Content-Type must be listed to
+	// send values such as application/json.
+	AllowedHeaders []string
+	// ExposedHeaders is the list of response headers that scripts can read.
+	ExposedHeaders []string
+	// AllowCredentials allows requests with cookies and HTTP authentication.
+	AllowCredentials bool
+	// MaxAge is the number of seconds that the result of a preflight
+	// request can be cached. Zero omits the header.
+	MaxAge int
+	// AllowPrivateNetwork allows requests from public websites to this
+	// server on a private network, as defined by Private Network Access.
+	AllowPrivateNetwork bool
+}
+
+// CORS is middleware that implements Cross-Origin Resource Sharing.
+// Preflight requests are answered with 204 No Content status without
+// calling the handler, and the CORS headers are omitted if the origin, the
+// method or a header is not allowed. The Vary header lists the request
+// headers that the response depends on.
+// It panics if "*" is used in AllowedOrigins or AllowedHeaders together
+// with AllowCredentials, which browsers reject.
+func CORS(cfg CORSConfig) func(http.Handler) http.Handler {
+	c := newCORS(cfg)
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodOptions && r.Header.Get("Origin") != "" &&
+				r.Header.Get("Access-Control-Request-Method") != "" {
+				c.preflight(w, r)
+				return
+			}
+			c.actual(w, r)
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+type cors struct {
+	cfg       CORSConfig
+	anyOrigin bool
+	anyHeader bool
+	origins   map[string]bool
+	wildcards [][2]string
+	methods   []string
+	exposed   string
+	maxAge    string
+}
+
+func newCORS(cfg CORSConfig) *cors {
+	c := &cors{cfg: cfg, origins: map[string]bool{}}
+	for _, o := range cfg.AllowedOrigins {
+		o = strings.ToLower(o)
+		switch i := strings.IndexByte(o, '*'); {
+		case o == "*":
+			c.anyOrigin = true
+		case i >= 0:
+			c.wildcards = append(c.wildcards, [2]string{o[:i], o[i+1:]})
+		default:
+			c.origins[o] = true
+		}
+	}
+	for _, h := range cfg.AllowedHeaders {
+		if h == "*" {
+			c.anyHeader = true
+		}
+	}
+	if cfg.AllowCredentials && (c.anyOrigin || c.anyHeader) {
+		panic("umbrella: CORS wildcard cannot be used with credentials")
+	}
+	c.methods = cfg.AllowedMethods
+	if len(c.methods) == 0 {
+		c.methods = []string{http.MethodGet, http.MethodHead, http.MethodPost}
+	}
+	c.exposed = strings.Join(cfg.ExposedHeaders, ", ")
+	if cfg.MaxAge > 0 {
+		c.maxAge = strconv.Itoa(cfg.MaxAge)
+	}
+	return c
+}
+
+func (c *cors) preflight(w http.ResponseWriter, r *http.Request) {
+	header := w.Header()
+	addVary(header, "Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers")
+	if c.cfg.AllowPrivateNetwork {
+		addVary(header, "Access-Control-Request-Private-Network")
+	}
+	defer w.WriteHeader(http.StatusNoContent)
+
+	origin := r.Header.Get("Origin")
+	if !c.allowOrigin(origin) || !c.allowMethod(r.Header.Get("Access-Control-Request-Method")) {
+		return
+	}
+	requested := splitHeaderList(strings.Join(r.Header.Values("Access-Control-Request-Headers"), ","), ',')
+	if !c.allowHeaders(requested) {
+		return
+	}
+	c.setOrigin(header, origin)
+	header.Set("Access-Control-Allow-Methods", strings.Join(c.methods, ", "))
+	if len(requested) > 0 {
+		header.Set("Access-Control-Allow-Headers", strings.ToLower(strings.Join(requested, ", ")))
+	}
+	if c.maxAge != "" {
+		header.Set("Access-Control-Max-Age", c.maxAge)
+	}
+	if c.cfg.AllowPrivateNetwork && r.Header.Get("Access-Control-Request-Private-Network") == "true" {
+		header.Set("Access-Control-Allow-Private-Network", "true")
+	}
+}
+
+func (c *cors) actual(w http.ResponseWriter, r *http.Request) {
+	header := w.Header()
+	if !c.anyOrigin {
+		addVary(header, "Origin")
+	}
+	origin := r.Header.Get("Origin")
+	if !c.allowOrigin(origin) {
+		return
+	}
+	c.setOrigin(header, origin)
+	if c.exposed != "" {
+		header.Set("Access-Control-Expose-Headers", c.exposed)
+	}
+}
+
+func (c *cors) setOrigin(header http.Header, origin string) {
+	if c.anyOrigin {
+		header.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		header.Set("Access-Control-Allow-Origin", origin)
+	}
+	if c.cfg.AllowCredentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+// allowOrigin reports whether the origin can make cross-origin requests.
+func (c *cors) allowOrigin(origin string) bool {
+	if origin == "" {
+		return false
+	}
+	if c.anyOrigin {
+		return true
+	}
+	o := strings.ToLower(origin)
+	if c.origins[o] {
+		return true
+	}
+	for _, w := range c.wildcards {
+		if len(o) > len(w[0])+len(w[1]) && strings.HasPrefix(o, w[0]) && strings.HasSuffix(o, w[1]) &&
+			!strings.ContainsAny(o[len(w[0]):len(o)-len(w[1])], "/:") {
+			return true
+		}
+	}
+	return c.cfg.AllowOriginFunc != nil && c.cfg.AllowOriginFunc(origin)
+}
+
+// allowMethod reports whether the method is allowed. Methods are case
+// sensitive, as in the Fetch standard.
+func (c *cors) allowMethod(method string) bool {
+	for _, m := range c.methods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// allowHeaders reports whether all the headers are allowed.
+func (c *cors) allowHeaders(headers []string) bool {
+	if c.anyHeader {
+		return true
+	}
+	for _, h := range headers {
+		found := false
+		for _, allowed := range c.cfg.AllowedHeaders {
+			if strings.EqualFold(h, allowed) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,185 @@
+package umbrella
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCORS(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	t.Run("case=actual", func(t *testing.T) {
+		mw := CORS(CORSConfig{
+			AllowedOrigins: []string{"https://example.com", "https://*.example.net"},
+			AllowOriginFunc: func(origin string) bool {
+				return strings.HasSuffix(origin, ".localhost:3000")
+			},
+			ExposedHeaders:   []string{"X-Request-Id", "ETag"},
+			AllowCredentials: true,
+		})
+		testCases := []struct {
+			origin string
+			allow  bool
+		}{
+			{origin: "", allow: false},
+			{origin: "https://example.com", allow: true},
+			{origin: "https://EXAMPLE.com", allow: true},
+			{origin: "http://example.com", allow: false},
+			{origin: "https://example.com.evil.test", allow: false},
+			{origin: "https://api.example.net", allow: true},
+			{origin: "https://a.b.example.net", allow: true},
+			{origin: "https://example.net", allow: false},
+			{origin: "https://evil.test/.example.net", allow: false},
+			{origin: "https://evilexample.net", allow: false},
+			{origin: "http://app.localhost:3000", allow: true},
+			{origin: "null", allow: false},
+		}
+		for _, tc := range testCases {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.origin != "" {
+				r.Header.Set("Origin", tc.origin)
+			}
+			w := httptest.NewRecorder()
+			mw(handler).ServeHTTP(w, r)
+			if got, want := w.Code, http.StatusOK; got != want {
+				t.Errorf("%s: got: %v, want: %v", tc.origin, got, want)
+			}
+			if got, want := w.Header().Get("Vary"), "Origin"; got != want {
+				t.Errorf("%s: got: %v, want: %v", tc.origin, got, want)
+			}
+			want := ""
+			if tc.allow {
+				want = tc.origin
+			}
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != want {
+				t.Errorf("%s: got: %v, want: %v", tc.origin, got, want)
+			}
+			if got, want := w.Header().Get("Access-Control-Allow-Credentials") == "true", tc.allow; got != want {
+				t.Errorf("%s: got: %v, want: %v", tc.origin, got, want)
+			}
+			if got, want := w.Header().Get("Access-Control-Expose-Headers") != "", tc.allow; got != want {
+				t.Errorf("%s: got: %v, want: %v", tc.origin, got, want)
+			}
+		}
+	})
+
+	t.Run("case=any-origin", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Origin", "https://example.com")
+		w := httptest.NewRecorder()
+		CORS(CORSConfig{AllowedOrigins: []string{"*"}})(handler).ServeHTTP(w, r)
+		if got, want := w.Header().Get("Access-Control-Allow-Origin"), "*"; got != want {
+			t.Errorf("got: %v, want: %v", got, want)
+		}
+		if got, want := w.Header().Get("Vary"), ""; got != want {
+			t.Errorf("got: %v, want: %v", got, want)
+		}
+	})
+
+	t.Run("case=preflight", func(t *testing.T) {
+		called := false
+		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			called = true
+		})
+		mw := CORS(CORSConfig{
+			AllowedOrigins:      []string{"https://example.com"},
+			AllowedMethods:      []string{http.MethodGet, http.MethodPut, http.MethodDelete},
+			AllowedHeaders:      []string{"Content-Type", "Authorization"},
+			MaxAge:              600,
+			AllowPrivateNetwork: true,
+		})
+		testCases := []struct {
+			origin  string
+			method  string
+			headers string
+			private bool
+			allow   bool
+		}{
+			{origin: "https://example.com", method: http.MethodPut, headers: "content-type, authorization", allow: true},
+			{origin: "https://example.com", method: http.MethodDelete, allow: true},
+			{origin: "https://example.com", method: http.MethodGet, private: true, allow: true},
+			{origin: "https://example.org", method: http.MethodPut},
+			{origin: "https://example.com", method: http.MethodPatch},
+			{origin: "https://example.com", method: "put"},
+			{origin: "https://example.com", method: http.MethodPut, headers: "content-type, x-custom"},
+		}
+		for _, tc := range testCases {
+			called = false
+			r := httptest.NewRequest(http.MethodOptions, "/", nil)
+			r.Header.Set("Origin", tc.origin)
+			r.Header.Set("Access-Control-Request-Method", tc.method)
+			if tc.headers != "" {
+				r.Header.Set("Access-Control-Request-Headers", tc.headers)
+			}
+			if tc.private {
+				r.Header.Set("Access-Control-Request-Private-Network", "true")
+			}
+			w := httptest.NewRecorder()
+			mw(h).ServeHTTP(w, r)
+			if called {
+				t.Errorf("%+v: handler called", tc)
+			}
+			if got, want := w.Code, http.StatusNoContent; got != want {
+				t.Errorf("%+v: got: %v, want: %v", tc, got, want)
+			}
+			if got, want := strings.Join(w.Header().Values("Vary"), ", "), "Origin, Access-Control-Request-Method, Access-Control-Request-Headers, Access-Control-Request-Private-Network"; got != want {
+				t.Errorf("%+v: got: %v, want: %v", tc, got, want)
+			}
+			want := map[string]string{
+				"Access-Control-Allow-Origin":          "",
+				"Access-Control-Allow-Methods":         "",
+				"Access-Control-Allow-Headers":         "",
+				"Access-Control-Max-Age":               "",
+				"Access-Control-Allow-Private-Network": "",
+			}
+			if tc.allow {
+				want["Access-Control-Allow-Origin"] = tc.origin
+				want["Access-Control-Allow-Methods"] = "GET, PUT, DELETE"
+				want["Access-Control-Allow-Headers"] = tc.headers
+				want["Access-Control-Max-Age"] = "600"
+				if tc.private {
+					want["Access-Control-Allow-Private-Network"] = "true"
+				}
+			}
+			for name, want := range want {
+				if got := w.Header().Get(name); got != want {
+					t.Errorf("%+v: %s: got: %v, want: %v", tc, name, got, want)
+				}
+			}
+		}
+	})
+
+	t.Run("case=options", func(t *testing.T) {
+		// OPTIONS requests that are not preflight requests reach the handler.
+		r := httptest.NewRequest(http.MethodOptions, "/", nil)
+		r.Header.Set("Origin", "https://example.com")
+		w := httptest.NewRecorder()
+		CORS(CORSConfig{AllowedOrigins: []string{"https://example.com"}})(handler).ServeHTTP(w, r)
+		if got, want := w.Code, http.StatusOK; got != want {
+			t.Errorf("got: %v, want: %v", got, want)
+		}
+		if got, want := w.Header().Get("Access-Control-Allow-Origin"), "https://example.com"; got != want {
+			t.Errorf("got: %v, want: %v", got, want)
+		}
+	})
+
+	t.Run("case=invalid", func(t *testing.T) {
+		for _, cfg := range []CORSConfig{
+			{AllowedOrigins: []string{"*"}, AllowCredentials: true},
+			{AllowedOrigins: []string{"https://example.com"}, AllowedHeaders: []string{"*"}, AllowCredentials: true},
+		} {
+			func() {
+				defer func() {
+					if recover() == nil {
+						t.Errorf("%+v: expected panic", cfg)
+					}
+				}()
+				CORS(cfg)
+			}()
+		}
+	})
+}
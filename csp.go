@@ -0,0 +1,328 @@
+package umbrella
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// Source keywords of the Content-Security-Policy header.
+const (
+	CSPSelf           = "'self'"
+	CSPNone           = "'none'"
+	CSPUnsafeInline   = "'unsafe-inline'"
+	CSPUnsafeEval     = "'unsafe-eval'"
+	CSPUnsafeHashes   = "'unsafe-hashes'"
+	CSPStrictDynamic  = "'strict-dynamic'"
+	CSPWasmUnsafeEval = "'wasm-unsafe-eval'"
+	CSPReportSample   = "'report-sample'"
+)
+
+// ContentSecurityPolicy builds the Content-Security-Policy header.
+// Methods add directives and return the policy so that calls can be
+// chained. Directives are written in the order they were first added.
+type ContentSecurityPolicy struct {
+	directives []cspDirective
+	reportOnly bool
+	nonce      bool
+	endpoints  []string
+}
+
+type cspDirective struct {
+	name    string
+	sources []string
+}
+
+type cspNonceKey struct{}
+
+// NewContentSecurityPolicy creates and returns a new empty
+// ContentSecurityPolicy.
+func NewContentSecurityPolicy() *ContentSecurityPolicy {
+	return &ContentSecurityPolicy{}
+}
+
+// Directive adds the sources to the directive. It panics if the name or a
+// source contains characters that would break the header.
+func (p *ContentSecurityPolicy) Directive(name string, sources ...string) *ContentSecurityPolicy {
+	name = strings.ToLower(name)
+	if name == "" || !isCSPToken(name) {
+		panic(fmt.Sprintf("umbrella: invalid CSP directive: %q", name))
+	}
+	for _, source := range sources {
+		if !isCSPToken(source) {
+			panic(fmt.Sprintf("umbrella: invalid CSP source: %q", source))
+		}
+	}
+	for i := range p.directives {
+		if p.directives[i].name == name {
+			p.directives[i].sources = append(p.directives[i].sources, sources...)
+			return p
+		}
+	}
+	p.directives = append(p.directives, cspDirective{name: name, sources: sources})
+	return p
+}
+
+// DefaultSrc adds the sources to the default-src directive.
+func (p *ContentSecurityPolicy) DefaultSrc(sources ...string) *ContentSecurityPolicy {
+	return p.Directive("default-src", sources...)
+}
+
+// ScriptSrc adds the sources to the script-src directive.
+func (p *ContentSecurityPolicy) ScriptSrc(sources ...string) *ContentSecurityPolicy {
+	return p.Directive("script-src", sources...)
+}
+
+// StyleSrc adds the sources to the style-src directive.
+func (p *ContentSecurityPolicy) StyleSrc(sources ...string) *ContentSecurityPolicy {
+	return p.Directive("style-src", sources...)
+}
+
+// ImgSrc adds the sources to the img-src directive.
+func (p *ContentSecurityPolicy) ImgSrc(sources ...string) *ContentSecurityPolicy {
+	return p.Directive("img-src", sources...)
+}
+
+// ConnectSrc adds the sources to the connect-src directive.
+func (p *ContentSecurityPolicy) ConnectSrc(sources ...string) *ContentSecurityPolicy {
+	return p.Directive("connect-src", sources...)
+}
+
+// FontSrc adds the sources to the font-src directive.
+func (p *ContentSecurityPolicy) FontSrc(sources ...string) *ContentSecurityPolicy {
+	return p.Directive("font-src", sources...)
+}
+
+// ObjectSrc adds the sources to the object-src directive.
+func (p *ContentSecurityPolicy) ObjectSrc(sources ...string) *ContentSecurityPolicy {
+	return p.Directive("object-src", sources...)
+}
+
+// MediaSrc adds the sources to the media-src directive.
+func (p *ContentSecurityPolicy) MediaSrc(sources ...string) *ContentSecurityPolicy {
+	return p.Directive("media-src", sources...)
+}
+
+// FrameSrc adds the sources to the frame-src directive.
+func (p *ContentSecurityPolicy) FrameSrc(sources ...string) *ContentSecurityPolicy {
+	return p.Directive("frame-src", sources...)
+}
+
+// WorkerSrc adds the sources to the worker-src directive.
+func (p *ContentSecurityPolicy) WorkerSrc(sources ...string) *ContentSecurityPolicy {
+	return p.Directive("worker-src", sources...)
+}
+
+// ManifestSrc adds the sources to the manifest-src directive.
+func (p *ContentSecurityPolicy) ManifestSrc(sources ...string) *ContentSecurityPolicy {
+	return p.Directive("manifest-src", sources...)
+}
+
+// FrameAncestors adds the sources to the frame-ancestors directive.
+func (p *ContentSecurityPolicy) FrameAncestors(sources ...string) *ContentSecurityPolicy {
+	return p.Directive("frame-ancestors", sources...)
+}
+
+// BaseURI adds the sources to the base-uri directive.
+func (p *ContentSecurityPolicy) BaseURI(sources ...string) *ContentSecurityPolicy {
+	return p.Directive("base-uri", sources...)
+}
+
+// FormAction adds the sources to the form-action directive.
+func (p *ContentSecurityPolicy) FormAction(sources ...string) *ContentSecurityPolicy {
+	return p.Directive("form-action", sources...)
+}
+
+// Sandbox adds the sandbox directive with the allowed features, such as
+// "allow-scripts".
+func (p *ContentSecurityPolicy) Sandbox(values ...string) *ContentSecurityPolicy {
+	return p.Directive("sandbox", values...)
+}
+
+// UpgradeInsecureRequests adds the upgrade-insecure-requests directive.
+func (p *ContentSecurityPolicy) UpgradeInsecureRequests() *ContentSecurityPolicy {
+	return p.Directive("upgrade-insecure-requests")
+}
+
+// ReportURI adds the report-uri directive, which sends violation reports to
+// the URI in the legacy application/csp-report format.
+func (p *ContentSecurityPolicy) ReportURI(uri string) *ContentSecurityPolicy {
+	return p.Directive("report-uri", uri)
+}
+
+// ReportTo adds the report-to directive and defines the endpoint in the
+// Reporting-Endpoints header, which sends violation reports to the URL with
+// the Reporting API.
+func (p *ContentSecurityPolicy) ReportTo(group, url string) *ContentSecurityPolicy {
+	if !isCSPToken(group) || strings.ContainsAny(url, "\",\r\n") {
+		panic(fmt.Sprintf("umbrella: invalid reporting endpoint: %q=%q", group, url))
+	}
+	p.endpoints = append(p.endpoints, fmt.Sprintf("%s=%q", group, url))
+	return p.Directive("report-to", group)
+}
+
+// ReportOnly sends the policy with the Content-Security-Policy-Report-Only
+// header, which reports violations without enforcing the policy.
+func (p *ContentSecurityPolicy) ReportOnly() *ContentSecurityPolicy {
+	p.reportOnly = true
+	return p
+}
+
+// Nonce makes the middleware generate a random nonce for each request and
+// add it to the script-src and style-src directives. Directives that are
+// not set inherit the sources of default-src, and directives that are
+// 'none' are left as is. The nonce is available with CSPNonce.
+func (p *ContentSecurityPolicy) Nonce() *ContentSecurityPolicy {
+	p.nonce = true
+	return p
+}
+
+// String returns the value of the header without a nonce.
+func (p *ContentSecurityPolicy) String() string {
+	return p.value("")
+}
+
+// HeaderName returns the name of the header, which is
+// Content-Security-Policy or Content-Security-Policy-Report-Only.
+func (p *ContentSecurityPolicy) HeaderName() string {
+	if p.reportOnly {
+		return "Content-Security-Policy-Report-Only"
+	}
+	return "Content-Security-Policy"
+}
+
+// HeaderFunc returns a HeaderFunc that adds the policy without a nonce.
+func (p *ContentSecurityPolicy) HeaderFunc() HeaderFunc {
+	name, value, endpoints := p.HeaderName(), p.String(), strings.Join(p.endpoints, ", ")
+	return func(header http.Header) {
+		header.Set(name, value)
+		if endpoints != "" {
+			header.Set("Reporting-Endpoints", endpoints)
+		}
+	}
+}
+
+// Middleware returns middleware that adds the policy to the response.
+// If Nonce is enabled, a new nonce is generated for each request.
+// Changes to the policy after calling Middleware are not reflected.
+func (p *ContentSecurityPolicy) Middleware() func(http.Handler) http.Handler {
+	if !p.nonce {
+		return ResponseHeader(p.HeaderFunc())
+	}
+	p2 := p.clone()
+	name, endpoints := p2.HeaderName(), strings.Join(p2.endpoints, ", ")
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			nonce, err := newCSPNonce()
+			if err != nil {
+				log.Printf("csp.error: %v", err)
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set(name, p2.value(nonce))
+			if endpoints != "" {
+				w.Header().Set("Reporting-Endpoints", endpoints)
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), cspNonceKey{}, nonce)))
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// CSPNonce returns the nonce generated for the request by the
+// ContentSecurityPolicy middleware, or an empty string. Use it in the nonce
+// attribute of script and style elements.
+func CSPNonce(r *http.Request) string {
+	nonce, _ := r.Context().Value(cspNonceKey{}).(string)
+	return nonce
+}
+
+// CSPHash returns the 'sha256-...' source that allows the inline script or
+// style with the content.
+func CSPHash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return "'sha256-" + base64.StdEncoding.EncodeToString(sum[:]) + "'"
+}
+
+func (p *ContentSecurityPolicy) clone() *ContentSecurityPolicy {
+	p2 := *p
+	p2.directives = make([]cspDirective, len(p.directives))
+	for i, d := range p.directives {
+		p2.directives[i] = cspDirective{name: d.name, sources: append([]string(nil), d.sources...)}
+	}
+	p2.endpoints = append([]string(nil), p.endpoints...)
+	return &p2
+}
+
+// value returns the value of the header with the nonce, if not empty.
+func (p *ContentSecurityPolicy) value(nonce string) string {
+	directives := p.directives
+	if nonce != "" {
+		directives = p.withNonce("'nonce-" + nonce + "'")
+	}
+	list := make([]string, len(directives))
+	for i, d := range directives {
+		list[i] = strings.Join(append([]string{d.name}, d.sources...), " ")
+	}
+	return strings.Join(list, "; ")
+}
+
+// withNonce returns the directives with the nonce source added to
+// script-src and style-src.
+func (p *ContentSecurityPolicy) withNonce(source string) []cspDirective {
+	var defaultSrc []string
+	for _, d := range p.directives {
+		if d.name == "default-src" {
+			defaultSrc = d.sources
+		}
+	}
+	directives := make([]cspDirective, 0, len(p.directives)+2)
+	found := map[string]bool{}
+	for _, d := range p.directives {
+		if d.name == "script-src" || d.name == "style-src" {
+			found[d.name] = true
+			if !containsCSPNone(d.sources) {
+				d.sources = append(append([]string(nil), d.sources...), source)
+			}
+		}
+		directives = append(directives, d)
+	}
+	if defaultSrc != nil && !containsCSPNone(defaultSrc) {
+		for _, name := range []string{"script-src", "style-src"} {
+			if !found[name] {
+				sources := append(append([]string(nil), defaultSrc...), source)
+				directives = append(directives, cspDirective{name: name, sources: sources})
+			}
+		}
+	}
+	return directives
+}
+
+func containsCSPNone(sources []string) bool {
+	for _, s := range sources {
+		if strings.EqualFold(s, CSPNone) {
+			return true
+		}
+	}
+	return false
+}
+
+// isCSPToken reports whether s can be written in the header without
+// starting a new directive or policy.
+func isCSPToken(s string) bool {
+	return s != "" && !strings.ContainsAny(s, ";, \t\r\n")
+}
+
+// newCSPNonce returns a base64 encoded random nonce of 128 bits.
+func newCSPNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,132 @@
+package umbrella
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestContentSecurityPolicy(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	t.Run("case=header", func(t *testing.T) {
+		p := NewContentSecurityPolicy().
+			DefaultSrc(CSPSelf).
+			ImgSrc(CSPSelf, "data:").
+			ImgSrc("https://images.example.com").
+			ObjectSrc(CSPNone).
+			FrameAncestors(CSPNone).
+			UpgradeInsecureRequests().
+			ReportURI("/csp-report").
+			ReportTo("csp", "https://example.com/reports")
+		want := "default-src 'self'; img-src 'self' data: https://images.example.com; object-src 'none'; frame-ancestors 'none'; upgrade-insecure-requests; report-uri /csp-report; report-to csp"
+		if got := p.String(); got != want {
+			t.Errorf("got: %v, want: %v", got, want)
+		}
+
+		w := httptest.NewRecorder()
+		p.Middleware()(handler).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		if got := w.Header().Get("Content-Security-Policy"); got != want {
+			t.Errorf("got: %v, want: %v", got, want)
+		}
+		if got, want := w.Header().Get("Reporting-Endpoints"), `csp="https://example.com/reports"`; got != want {
+			t.Errorf("got: %v, want: %v", got, want)
+		}
+	})
+
+	t.Run("case=report only", func(t *testing.T) {
+		p := NewContentSecurityPolicy().DefaultSrc(CSPSelf).ReportOnly()
+		w := httptest.NewRecorder()
+		ResponseHeader(p.HeaderFunc())(handler).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		if got, want := w.Header().Get("Content-Security-Policy-Report-Only"), "default-src 'self'"; got != want {
+			t.Errorf("got: %v, want: %v", got, want)
+		}
+		if got := w.Header().Get("Content-Security-Policy"); got != "" {
+			t.Errorf("got: %v, want: empty", got)
+		}
+	})
+
+	t.Run("case=nonce", func(t *testing.T) {
+		p := NewContentSecurityPolicy().
+			DefaultSrc(CSPSelf).
+			ScriptSrc(CSPStrictDynamic).
+			Nonce()
+		mw := p.Middleware()
+		// Changes after Middleware are not reflected.
+		p.ObjectSrc(CSPNone)
+
+		nonces := map[string]bool{}
+		for i := 0; i < 3; i++ {
+			var nonce string
+			h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				nonce = CSPNonce(r)
+			})
+			w := httptest.NewRecorder()
+			mw(h).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+			if raw, err := base64.StdEncoding.DecodeString(nonce); err != nil || len(raw) != 16 {
+				t.Errorf("invalid nonce: %q", nonce)
+			}
+			if nonces[nonce] {
+				t.Errorf("nonce reused: %q", nonce)
+			}
+			nonces[nonce] = true
+			want := "default-src 'self'; script-src 'strict-dynamic' 'nonce-" + nonce + "'; style-src 'self' 'nonce-" + nonce + "'"
+			if got := w.Header().Get("Content-Security-Policy"); got != want {
+				t.Errorf("got: %v, want: %v", got, want)
+			}
+		}
+		if got, want := p.String(), "default-src 'self'; script-src 'strict-dynamic'; object-src 'none'"; got != want {
+			t.Errorf("got: %v, want: %v", got, want)
+		}
+	})
+
+	t.Run("case=nonce none", func(t *testing.T) {
+		p := NewContentSecurityPolicy().StyleSrc(CSPNone).Nonce()
+		var nonce string
+		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			nonce = CSPNonce(r)
+		})
+		w := httptest.NewRecorder()
+		p.Middleware()(h).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		if nonce == "" {
+			t.Error("got: empty, want: nonce")
+		}
+		if got, want := w.Header().Get("Content-Security-Policy"), "style-src 'none'"; got != want {
+			t.Errorf("got: %v, want: %v", got, want)
+		}
+	})
+
+	t.Run("case=without middleware", func(t *testing.T) {
+		if got := CSPNonce(httptest.NewRequest(http.MethodGet, "/", nil)); got != "" {
+			t.Errorf("got: %v, want: empty", got)
+		}
+	})
+
+	t.Run("case=invalid", func(t *testing.T) {
+		for _, f := range []func(){
+			func() { NewContentSecurityPolicy().ScriptSrc("'self'; script-src *") },
+			func() { NewContentSecurityPolicy().Directive("img-src,", CSPSelf) },
+			func() { NewContentSecurityPolicy().ReportURI("/a b") },
+			func() { NewContentSecurityPolicy().ReportTo("csp", `https://example.com/"`) },
+		} {
+			func() {
+				defer func() {
+					if recover() == nil {
+						t.Error("got: nil, want: panic")
+					}
+				}()
+				f()
+			}()
+		}
+	})
+}
+
+func TestCSPHash(t *testing.T) {
+	// The example of CSP Level 3.
+	if got, want := CSPHash("alert('Hello, world.');"), "'sha256-qznLcsROx4GACP2dm0UCKCzCG+HiZ1guq6ZZDob/Tng='"; got != want {
+		t.Errorf("got: %v, want: %v", got, want)
+	}
+}
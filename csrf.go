@@ -0,0 +1,259 @@
+package umbrella
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// csrfTokenSize is the size of a raw token and of its signature.
+const csrfTokenSize = 32
+
+// CSRFStore saves the token of a client.
+// The stored value is signed and is checked when it is read.
+type CSRFStore interface {
+	// Get returns the token saved for the client of the request, or nil.
+	Get(r *http.Request) ([]byte, error)
+	// Save saves the token for the client of the request.
+	Save(w http.ResponseWriter, r *http.Request, token []byte) error
+}
+
+// CSRFConfig is the configuration for CSRF.
+type CSRFConfig struct {
+	// Secret is the key used to sign tokens. It must be at least 32 bytes.
+	Secret []byte
+	// Store saves tokens for synchronizer token protection, for example in
+	// a server-side session. If nil, tokens are saved in a cookie for
+	// double-submit cookie protection.
+	Store CSRFStore
+	// Cookie is the template of the cookie used when Store is nil.
+	// The default name is "_csrf" and the default path is "/". HttpOnly is
+	// always set, Secure is set for HTTPS requests, and the default
+	// SameSite is Lax.
+	Cookie http.Cookie
+	// HeaderName is the name of the request header that carries the token,
+	// and of the response header that exposes it. The default is
+	// X-CSRF-Token.
+	HeaderName string
+	// FormField is the name of the form field that carries the token.
+	// The default is "csrf_token".
+	FormField string
+	// TrustedOrigins is the list of origins other than the origin of the
+	// request that can send unsafe requests, such as
+	// "https://app.example.com".
+	TrustedOrigins []string
+	// Exempt reports whether the request is not protected, for example
+	// webhooks authenticated by other means.
+	Exempt func(r *http.Request) bool
+	// ErrorHandler handles rejected requests. The default responds with
+	// 403 Forbidden status.
+	ErrorHandler http.Handler
+}
+
+type csrfTokenKey struct{}
+
+// CSRF is middleware that protects against cross-site request forgery.
+// Each client is given a random token signed with HMAC, and requests with
+// methods other than GET, HEAD, OPTIONS and TRACE must send it in the
+// header or the form field. The token is masked with a new random value
+// for each request to resist BREACH attacks, and is available with
+// CSRFToken and in the response header.
+// Requests with an Origin header must come from the origin of the request
+// or a trusted origin, and HTTPS requests without an Origin header must
+// have a Referer header from such an origin.
+// It panics if the secret is shorter than 32 bytes.
+func CSRF(cfg CSRFConfig) func(http.Handler) http.Handler {
+	if len(cfg.Secret) < csrfTokenSize {
+		panic("umbrella: CSRF secret must be at least 32 bytes")
+	}
+	if cfg.HeaderName == "" {
+		cfg.HeaderName = "X-CSRF-Token"
+	}
+	if cfg.FormField == "" {
+		cfg.FormField = "csrf_token"
+	}
+	if cfg.Store == nil {
+		cfg.Store = newCSRFCookieStore(cfg.Cookie)
+	}
+	if cfg.ErrorHandler == nil {
+		cfg.ErrorHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		})
+	}
+	trusted := make(map[string]bool, len(cfg.TrustedOrigins))
+	for _, o := range cfg.TrustedOrigins {
+		trusted[strings.ToLower(o)] = true
+	}
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			token, err := csrfLoadToken(cfg, w, r)
+			if err != nil {
+				log.Printf("csrf.error: %v", err)
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+			masked, err := maskCSRFToken(token)
+			if err != nil {
+				log.Printf("csrf.error: %v", err)
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set(cfg.HeaderName, masked)
+			r = r.WithContext(context.WithValue(r.Context(), csrfTokenKey{}, masked))
+
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+				next.ServeHTTP(w, r)
+				return
+			}
+			if cfg.Exempt != nil && cfg.Exempt(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if !csrfCheckOrigin(r, trusted) {
+				cfg.ErrorHandler.ServeHTTP(w, r)
+				return
+			}
+			sent := r.Header.Get(cfg.HeaderName)
+			if sent == "" {
+				sent = r.PostFormValue(cfg.FormField)
+			}
+			if !csrfTokenEqual(token, sent) {
+				cfg.ErrorHandler.ServeHTTP(w, r)
+				return
+			}
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// CSRFToken returns the masked token for the request generated by the CSRF
+// middleware, or an empty string. Use it in a form field or a request
+// header. The value changes for each request, and all values stay valid.
+func CSRFToken(r *http.Request) string {
+	token, _ := r.Context().Value(csrfTokenKey{}).(string)
+	return token
+}
+
+// csrfLoadToken returns the raw token of the client, creating and saving a
+// new one if the stored token is missing or has an invalid signature.
+func csrfLoadToken(cfg CSRFConfig, w http.ResponseWriter, r *http.Request) ([]byte, error) {
+	signed, err := cfg.Store.Get(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(signed) == 2*csrfTokenSize {
+		token := signed[:csrfTokenSize]
+		if hmac.Equal(signed[csrfTokenSize:], csrfSign(cfg.Secret, token)) {
+			return token, nil
+		}
+	}
+	token := make([]byte, csrfTokenSize)
+	if _, err := rand.Read(token); err != nil {
+		return nil, err
+	}
+	if err := cfg.Store.Save(w, r, append(append([]byte(nil), token...), csrfSign(cfg.Secret, token)...)); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+func csrfSign(secret, token []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(token)
+	return mac.Sum(nil)
+}
+
+// maskCSRFToken returns a random one-time pad followed by the token
+// encrypted with the pad, encoded in base64.
+func maskCSRFToken(token []byte) (string, error) {
+	b := make([]byte, 2*len(token))
+	if _, err := rand.Read(b[:len(token)]); err != nil {
+		return "", err
+	}
+	for i := range token {
+		b[len(token)+i] = b[i] ^ token[i]
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// csrfTokenEqual reports whether the masked token matches the raw token.
+func csrfTokenEqual(token []byte, masked string) bool {
+	b, err := base64.RawURLEncoding.DecodeString(masked)
+	if err != nil || len(b) != 2*len(token) {
+		return false
+	}
+	for i := range token {
+		b[i] ^= b[len(token)+i]
+	}
+	return subtle.ConstantTimeCompare(b[:len(token)], token) == 1
+}
+
+// csrfCheckOrigin reports whether the Origin or Referer header of the
+// request matches the origin of the request or a trusted origin.
+func csrfCheckOrigin(r *http.Request, trusted map[string]bool) bool {
+	info := GetClientInfo(r)
+	self := strings.ToLower(info.Scheme + "://" + info.Host)
+	if origin := r.Header.Get("Origin"); origin != "" {
+		origin = strings.ToLower(origin)
+		return origin == self || trusted[origin]
+	}
+	if info.Scheme != "https" {
+		return true
+	}
+	u, err := url.Parse(r.Referer())
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return false
+	}
+	origin := strings.ToLower(u.Scheme + "://" + u.Host)
+	return origin == self || trusted[origin]
+}
+
+// csrfCookieStore saves tokens in a cookie.
+type csrfCookieStore struct {
+	cookie http.Cookie
+}
+
+func newCSRFCookieStore(cookie http.Cookie) *csrfCookieStore {
+	if cookie.Name == "" {
+		cookie.Name = "_csrf"
+	}
+	if cookie.Path == "" {
+		cookie.Path = "/"
+	}
+	if cookie.SameSite == 0 {
+		cookie.SameSite = http.SameSiteLaxMode
+	}
+	cookie.HttpOnly = true
+	return &csrfCookieStore{cookie: cookie}
+}
+
+func (s *csrfCookieStore) Get(r *http.Request) ([]byte, error) {
+	c, err := r.Cookie(s.cookie.Name)
+	if err != nil {
+		return nil, nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(c.Value)
+	if err != nil {
+		return nil, nil
+	}
+	return b, nil
+}
+
+func (s *csrfCookieStore) Save(w http.ResponseWriter, r *http.Request, token []byte) error {
+	c := s.cookie
+	c.Value = base64.RawURLEncoding.EncodeToString(token)
+	if GetClientInfo(r).Scheme == "https" {
+		c.Secure = true
+	}
+	http.SetCookie(w, &c)
+	return nil
+}
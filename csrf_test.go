@@ -0,0 +1,215 @@
+package umbrella
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+type testCSRFStore struct {
+	tokens map[string][]byte
+}
+
+func (s *testCSRFStore) Get(r *http.Request) ([]byte, error) {
+	return s.tokens[r.Header.Get("X-Session")], nil
+}
+
+func (s *testCSRFStore) Save(w http.ResponseWriter, r *http.Request, token []byte) error {
+	s.tokens[r.Header.Get("X-Session")] = token
+	return nil
+}
+
+func TestCSRF(t *testing.T) {
+	secret := []byte(strings.Repeat("s", 32))
+	var token string
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token = CSRFToken(r)
+		w.WriteHeader(http.StatusOK)
+	})
+
+	t.Run("case=double-submit", func(t *testing.T) {
+		mw := CSRF(CSRFConfig{Secret: secret})(handler)
+
+		w := httptest.NewRecorder()
+		mw.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		if got, want := w.Code, http.StatusOK; got != want {
+			t.Fatalf("got: %v, want: %v", got, want)
+		}
+		cookies := w.Result().Cookies()
+		if got, want := len(cookies), 1; got != want {
+			t.Fatalf("got: %v, want: %v", got, want)
+		}
+		cookie := cookies[0]
+		if cookie.Name != "_csrf" || !cookie.HttpOnly || cookie.Secure || cookie.SameSite != http.SameSiteLaxMode {
+			t.Errorf("got: %#v", cookie)
+		}
+		if token == "" || w.Header().Get("X-CSRF-Token") != token {
+			t.Errorf("got: %v, want: %v", w.Header().Get("X-CSRF-Token"), token)
+		}
+
+		// The token is masked differently for each request.
+		first := token
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.AddCookie(cookie)
+		w = httptest.NewRecorder()
+		mw.ServeHTTP(w, r)
+		if got := len(w.Result().Cookies()); got != 0 {
+			t.Errorf("got: %v, want: %v", got, 0)
+		}
+		if token == first {
+			t.Errorf("token was not masked: %v", token)
+		}
+
+		testCases := []struct {
+			name   string
+			cookie *http.Cookie
+			header string
+			form   string
+			code   int
+		}{
+			{name: "header", cookie: cookie, header: first, code: http.StatusOK},
+			{name: "form", cookie: cookie, form: token, code: http.StatusOK},
+			{name: "no-token", cookie: cookie, code: http.StatusForbidden},
+			{name: "no-cookie", header: token, code: http.StatusForbidden},
+			{name: "invalid-token", cookie: cookie, header: "invalid", code: http.StatusForbidden},
+			{name: "forged-cookie", cookie: &http.Cookie{Name: "_csrf", Value: strings.Repeat("A", 86)}, header: token, code: http.StatusForbidden},
+		}
+		for _, tc := range testCases {
+			var r *http.Request
+			if tc.form != "" {
+				r = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(url.Values{"csrf_token": {tc.form}}.Encode()))
+				r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			} else {
+				r = httptest.NewRequest(http.MethodPost, "/", nil)
+			}
+			if tc.cookie != nil {
+				r.AddCookie(tc.cookie)
+			}
+			if tc.header != "" {
+				r.Header.Set("X-CSRF-Token", tc.header)
+			}
+			w := httptest.NewRecorder()
+			mw.ServeHTTP(w, r)
+			if got, want := w.Code, tc.code; got != want {
+				t.Errorf("%s: got: %v, want: %v", tc.name, got, want)
+			}
+		}
+	})
+
+	t.Run("case=synchronizer", func(t *testing.T) {
+		store := &testCSRFStore{tokens: map[string][]byte{}}
+		mw := CSRF(CSRFConfig{
+			Secret:     secret,
+			Store:      store,
+			HeaderName: "X-XSRF-Token",
+			Exempt: func(r *http.Request) bool {
+				return r.URL.Path == "/webhook"
+			},
+		})(handler)
+
+		get := func(session string) string {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("X-Session", session)
+			w := httptest.NewRecorder()
+			mw.ServeHTTP(w, r)
+			if got := len(w.Result().Cookies()); got != 0 {
+				t.Errorf("got: %v, want: %v", got, 0)
+			}
+			return w.Header().Get("X-XSRF-Token")
+		}
+		a, b := get("a"), get("b")
+		if got, want := len(store.tokens), 2; got != want {
+			t.Errorf("got: %v, want: %v", got, want)
+		}
+
+		testCases := []struct {
+			session string
+			path    string
+			token   string
+			code    int
+		}{
+			{session: "a", path: "/", token: a, code: http.StatusOK},
+			{session: "b", path: "/", token: b, code: http.StatusOK},
+			{session: "b", path: "/", token: a, code: http.StatusForbidden},
+			{session: "a", path: "/", code: http.StatusForbidden},
+			{session: "a", path: "/webhook", code: http.StatusOK},
+		}
+		for _, tc := range testCases {
+			r := httptest.NewRequest(http.MethodDelete, tc.path, nil)
+			r.Header.Set("X-Session", tc.session)
+			r.Header.Set("X-XSRF-Token", tc.token)
+			w := httptest.NewRecorder()
+			mw.ServeHTTP(w, r)
+			if got, want := w.Code, tc.code; got != want {
+				t.Errorf("%+v: got: %v, want: %v", tc, got, want)
+			}
+		}
+	})
+
+	t.Run("case=origin", func(t *testing.T) {
+		mw := CSRF(CSRFConfig{
+			Secret:         secret,
+			TrustedOrigins: []string{"https://app.example.com"},
+			ErrorHandler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusTeapot)
+			}),
+		})(handler)
+
+		w := httptest.NewRecorder()
+		mw.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "https://example.com/", nil))
+		cookie := w.Result().Cookies()[0]
+		if !cookie.Secure {
+			t.Errorf("got: %#v", cookie)
+		}
+
+		testCases := []struct {
+			tls     bool
+			origin  string
+			referer string
+			code    int
+		}{
+			{tls: true, origin: "https://example.com", code: http.StatusOK},
+			{tls: true, origin: "https://app.example.com", code: http.StatusOK},
+			{tls: true, origin: "https://evil.test", code: http.StatusTeapot},
+			{tls: true, origin: "http://example.com", code: http.StatusTeapot},
+			{tls: true, origin: "null", code: http.StatusTeapot},
+			{tls: true, referer: "https://example.com/form", code: http.StatusOK},
+			{tls: true, referer: "https://app.example.com/form", code: http.StatusOK},
+			{tls: true, referer: "https://evil.test/form", code: http.StatusTeapot},
+			{tls: true, code: http.StatusTeapot},
+			{tls: false, code: http.StatusOK},
+			{tls: false, origin: "https://evil.test", code: http.StatusTeapot},
+		}
+		for _, tc := range testCases {
+			r := httptest.NewRequest(http.MethodPost, "http://example.com/", nil)
+			if tc.tls {
+				r.TLS = &tls.ConnectionState{}
+			}
+			if tc.origin != "" {
+				r.Header.Set("Origin", tc.origin)
+			}
+			if tc.referer != "" {
+				r.Header.Set("Referer", tc.referer)
+			}
+			r.AddCookie(cookie)
+			r.Header.Set("X-CSRF-Token", token)
+			w := httptest.NewRecorder()
+			mw.ServeHTTP(w, r)
+			if got, want := w.Code, tc.code; got != want {
+				t.Errorf("%+v: got: %v, want: %v", tc, got, want)
+			}
+		}
+	})
+
+	t.Run("case=invalid", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected panic")
+			}
+		}()
+		CSRF(CSRFConfig{Secret: []byte("short")})
+	})
+}
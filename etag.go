@@ -1,32 +1,156 @@
 package umbrella
 
 import (
-	"crypto/md5"
+	"bytes"
 	"fmt"
+	"hash"
 	"net/http"
-	"net/http/httptest"
 )
 
-// ETag provides middleware that calculates MD5 from the response data and sets
-// it in the ETag header.
-func ETag() func(http.Handler) http.Handler {
+// ETag provides middleware that calculates a hash from the response data and
+// sets it in the ETag header.
+// Only 200 OK and 203 Non-Authoritative Information responses to GET and
+// HEAD requests that do not already have an ETag are tagged, and other
+// responses such as 206 Partial Content are passed through. Responses to
+// HEAD requests are tagged only if the handler writes the body as for GET
+// requests. The body is hashed while it is written and is buffered up to a
+// limit; larger responses are written through without an ETag.
+// The preconditions of GET and HEAD requests are evaluated with
+// CheckPreconditions against the ETag and the Last-Modified header of the
+// response, and the body is replaced with 304 Not Modified or 412
+// Precondition Failed. Other methods are passed through without evaluating
+// their preconditions, because the handler has already changed the
+// resource when the response is written; use OptimisticLock or
+// CheckPreconditions to reject them before the handler runs.
+func ETag(opts ...ETagOption) func(http.Handler) http.Handler {
+	o := newETagOptions(opts...)
 	return func(next http.Handler) http.Handler {
 		fn := func(w http.ResponseWriter, r *http.Request) {
-			rec := httptest.NewRecorder()
-			next.ServeHTTP(rec, r)
-			body := rec.Body.Bytes()
-			etag := fmt.Sprintf(`"%x"`, md5.Sum(body))
-			w.Header().Set("ETag", etag)
-			for k, v := range rec.Header() {
-				w.Header()[k] = v
-			}
-			if r.Header.Get("If-None-Match") == etag {
-				w.WriteHeader(http.StatusNotModified)
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				next.ServeHTTP(w, r)
 				return
 			}
-			w.WriteHeader(rec.Code)
-			_, _ = w.Write(body)
+			ew := &etagWriter{ResponseWriter: w, r: r, opts: o, hash: o.hash()}
+			next.ServeHTTP(ew, r)
+			ew.finish()
 		}
 		return http.HandlerFunc(fn)
 	}
 }
+
+type etagWriter struct {
+	http.ResponseWriter
+	r           *http.Request
+	opts        *etagOptions
+	hash        hash.Hash
+	buf         bytes.Buffer
+	code        int
+	wroteHeader bool
+	passThrough bool
+	discard     bool
+}
+
+func (w *etagWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	w.code = code
+	if code != http.StatusOK && code != http.StatusNonAuthoritativeInfo {
+		// A partial or empty response would be tagged with the hash of a
+		// different body than the full representation.
+		w.passThrough = true
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	// Responses that already have an ETag are not buffered, but their
+	// preconditions are still evaluated.
+	if etag := w.Header().Get("ETag"); etag != "" {
+		w.passThrough = true
+		if !w.writePrecondition(etag) {
+			w.ResponseWriter.WriteHeader(code)
+		}
+	}
+}
+
+func (w *etagWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.discard {
+		return len(p), nil
+	}
+	if w.passThrough {
+		return w.ResponseWriter.Write(p)
+	}
+	if w.buf.Len()+len(p) > w.opts.maxBufferSize {
+		// The response is too large to be buffered.
+		if err := w.writeBuffer(); err != nil {
+			return 0, err
+		}
+		return w.ResponseWriter.Write(p)
+	}
+	_, _ = w.hash.Write(p)
+	return w.buf.Write(p)
+}
+
+// Flush implements the http.Flusher. Flushed responses are not tagged.
+func (w *etagWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.passThrough {
+		_ = w.writeBuffer()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// writeBuffer writes the buffered response and switches to pass-through.
+func (w *etagWriter) writeBuffer() error {
+	w.passThrough = true
+	w.ResponseWriter.WriteHeader(w.code)
+	_, err := w.ResponseWriter.Write(w.buf.Bytes())
+	w.buf.Reset()
+	return err
+}
+
+// finish sets the ETag and writes the buffered response.
+func (w *etagWriter) finish() {
+	if w.passThrough {
+		return
+	}
+	if !w.wroteHeader {
+		w.code = http.StatusOK
+	}
+	if w.r.Method == http.MethodHead && w.buf.Len() == 0 {
+		// Handlers such as http.ServeContent do not write the body of HEAD
+		// requests, and the hash of the empty body would not match the
+		// ETag of GET requests.
+		w.ResponseWriter.WriteHeader(w.code)
+		return
+	}
+	etag := fmt.Sprintf(`"%x"`, w.hash.Sum(nil))
+	if w.opts.weak {
+		etag = "W/" + etag
+	}
+	w.Header().Set("ETag", etag)
+	if w.writePrecondition(etag) {
+		return
+	}
+	w.ResponseWriter.WriteHeader(w.code)
+	_, _ = w.ResponseWriter.Write(w.buf.Bytes())
+}
+
+// writePrecondition writes 304 Not Modified or 412 Precondition Failed if
+// the preconditions of the request are not met and reports whether it did.
+func (w *etagWriter) writePrecondition(etag string) bool {
+	code := CheckPreconditions(w.r, etag, lastModifiedTime(w.Header()))
+	if code == 0 {
+		return false
+	}
+	w.discard = true
+	writePreconditionStatus(w.ResponseWriter, code)
+	return true
+}
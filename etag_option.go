@@ -0,0 +1,55 @@
+package umbrella
+
+import (
+	"crypto/md5"
+	"hash"
+)
+
+// ETagOption ...
+type ETagOption func(o *etagOptions)
+
+type etagOptions struct {
+	hash          func() hash.Hash
+	maxBufferSize int
+	weak          bool
+}
+
+func newETagOptions(opts ...ETagOption) *etagOptions {
+	o := &etagOptions{
+		hash:          md5.New,
+		maxBufferSize: 1 << 20, // 1 MiB
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithETagHash sets the function that creates the hash used to calculate
+// the ETag. The default is MD5.
+func WithETagHash(fn func() hash.Hash) ETagOption {
+	return func(o *etagOptions) {
+		if fn != nil {
+			o.hash = fn
+		}
+	}
+}
+
+// WithETagMaxBufferSize sets the maximum size of the response body that is
+// buffered to calculate the ETag. Larger responses are written without an
+// ETag. The default is 1 MiB.
+func WithETagMaxBufferSize(n int) ETagOption {
+	return func(o *etagOptions) {
+		if n > 0 {
+			o.maxBufferSize = n
+		}
+	}
+}
+
+// WithWeakETag makes the middleware generate weak ETags (W/"...").
+// Weak ETags never match If-Match, which requires a strong comparison.
+func WithWeakETag() ETagOption {
+	return func(o *etagOptions) {
+		o.weak = true
+	}
+}
@@ -1,7 +1,12 @@
 package umbrella
 
 import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"fmt"
 	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 )
@@ -35,3 +40,188 @@ func TestEtag(t *testing.T) {
 	}
 	_ = resp.Body.Close()
 }
+
+func TestETagOptions(t *testing.T) {
+	body := bytes.Repeat([]byte("umbrella"), 16)
+	testCases := []struct {
+		name   string
+		opts   []ETagOption
+		method string
+		code   int
+		etag   string
+		flush  bool
+		want   string
+	}{
+		{
+			name: "sha256", opts: []ETagOption{WithETagHash(sha256.New)},
+			method: http.MethodGet, code: http.StatusOK,
+			want: fmt.Sprintf(`"%x"`, sha256.Sum256(body)),
+		},
+		{
+			name: "head", method: http.MethodHead, code: http.StatusOK,
+			want: fmt.Sprintf(`"%x"`, md5.Sum(body)),
+		},
+		{
+			name: "too-large", opts: []ETagOption{WithETagMaxBufferSize(len(body) - 1)},
+			method: http.MethodGet, code: http.StatusOK, want: "",
+		},
+		{name: "post", method: http.MethodPost, code: http.StatusOK, want: ""},
+		{name: "not-found", method: http.MethodGet, code: http.StatusNotFound, want: ""},
+		{name: "tagged", method: http.MethodGet, code: http.StatusOK, etag: `"v1"`, want: `"v1"`},
+		{name: "flush", method: http.MethodGet, code: http.StatusOK, flush: true, want: ""},
+	}
+	for _, tc := range testCases {
+		t.Run("case="+tc.name, func(t *testing.T) {
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tc.etag != "" {
+					w.Header().Set("ETag", tc.etag)
+				}
+				w.WriteHeader(tc.code)
+				w.Write(body[:len(body)/2])
+				if tc.flush {
+					w.(http.Flusher).Flush()
+				}
+				w.Write(body[len(body)/2:])
+			})
+			w := httptest.NewRecorder()
+			ETag(tc.opts...)(handler).ServeHTTP(w, httptest.NewRequest(tc.method, "/", nil))
+			if got, want := w.Code, tc.code; got != want {
+				t.Errorf("got: %v, want: %v", got, want)
+			}
+			if got, want := w.Header().Get("ETag"), tc.want; got != want {
+				t.Errorf("got: %v, want: %v", got, want)
+			}
+			if !bytes.Equal(w.Body.Bytes(), body) {
+				t.Errorf("got: %s, want: %s", w.Body.Bytes(), body)
+			}
+		})
+	}
+}
+
+func TestETagPreconditions(t *testing.T) {
+	body := []byte("umbrella")
+	etag := fmt.Sprintf(`"%x"`, md5.Sum(body))
+	testCases := []struct {
+		name   string
+		method string
+		opts   []ETagOption
+		tagged string
+		header http.Header
+		code   int
+		etag   string
+	}{
+		{name: "weak", opts: []ETagOption{WithWeakETag()}, header: http.Header{}, code: http.StatusOK, etag: "W/" + etag},
+		{name: "weak-if-none-match", opts: []ETagOption{WithWeakETag()}, header: http.Header{"If-None-Match": {etag}}, code: http.StatusNotModified, etag: "W/" + etag},
+		{name: "weak-if-match", opts: []ETagOption{WithWeakETag()}, header: http.Header{"If-Match": {"W/" + etag}}, code: http.StatusPreconditionFailed, etag: "W/" + etag},
+		{name: "if-none-match-list", header: http.Header{"If-None-Match": {`"x", ` + etag}}, code: http.StatusNotModified, etag: etag},
+		{name: "if-none-match-star", header: http.Header{"If-None-Match": {"*"}}, code: http.StatusNotModified, etag: etag},
+		{name: "if-match", header: http.Header{"If-Match": {etag}}, code: http.StatusOK, etag: etag},
+		{name: "if-match-mismatch", header: http.Header{"If-Match": {`"x"`}}, code: http.StatusPreconditionFailed, etag: etag},
+		{name: "tagged", tagged: `"v1"`, header: http.Header{"If-None-Match": {`"v1"`}}, code: http.StatusNotModified, etag: `"v1"`},
+		{name: "if-modified-since", header: http.Header{"If-Modified-Since": {time.Now().Format(http.TimeFormat)}}, code: http.StatusNotModified, etag: etag},
+		// Preconditions of unsafe methods are left to OptimisticLock.
+		{name: "unsafe-method", method: http.MethodPut, header: http.Header{"If-Match": {`"nope"`}}, code: http.StatusOK},
+	}
+	for _, tc := range testCases {
+		t.Run("case="+tc.name, func(t *testing.T) {
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tc.tagged != "" {
+					w.Header().Set("ETag", tc.tagged)
+				}
+				w.Header().Set("Last-Modified", time.Now().Add(-time.Hour).Format(http.TimeFormat))
+				w.WriteHeader(http.StatusOK)
+				w.Write(body)
+			})
+			method := tc.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			r := httptest.NewRequest(method, "/", nil)
+			r.Header = tc.header
+			w := httptest.NewRecorder()
+			ETag(tc.opts...)(handler).ServeHTTP(w, r)
+			if got, want := w.Code, tc.code; got != want {
+				t.Errorf("got: %v, want: %v", got, want)
+			}
+			if got, want := w.Header().Get("ETag"), tc.etag; got != want {
+				t.Errorf("got: %v, want: %v", got, want)
+			}
+			if tc.code != http.StatusOK && bytes.Equal(w.Body.Bytes(), body) {
+				t.Errorf("body must not be written")
+			}
+		})
+	}
+}
+
+func TestETagHead(t *testing.T) {
+	body := []byte("hello")
+	etag := fmt.Sprintf(`"%x"`, md5.Sum(body))
+	modtime := time.Now().Add(-time.Hour)
+	testCases := []struct {
+		name    string
+		handler http.HandlerFunc
+		etag    string
+	}{
+		{
+			// The body written for HEAD requests is hashed as for GET.
+			name: "body",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write(body)
+			},
+			etag: etag,
+		},
+		{
+			// http.ServeContent does not write the body of HEAD requests.
+			name: "serve-content",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				http.ServeContent(w, r, "hello.txt", modtime, bytes.NewReader(body))
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run("case="+tc.name, func(t *testing.T) {
+			get := httptest.NewRecorder()
+			ETag()(tc.handler).ServeHTTP(get, httptest.NewRequest(http.MethodGet, "/", nil))
+			if got, want := get.Header().Get("ETag"), etag; got != want {
+				t.Errorf("got: %v, want: %v", got, want)
+			}
+
+			head := httptest.NewRecorder()
+			ETag()(tc.handler).ServeHTTP(head, httptest.NewRequest(http.MethodHead, "/", nil))
+			if got, want := head.Code, http.StatusOK; got != want {
+				t.Errorf("got: %v, want: %v", got, want)
+			}
+			if got, want := head.Header().Get("ETag"), tc.etag; got != want {
+				t.Errorf("got: %v, want: %v", got, want)
+			}
+		})
+	}
+}
+
+func TestETagRange(t *testing.T) {
+	body := []byte("hello world")
+	modtime := time.Now().Add(-time.Hour)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "hello.txt", modtime, bytes.NewReader(body))
+	})
+
+	w := httptest.NewRecorder()
+	ETag()(handler).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if got, want := w.Header().Get("ETag"), fmt.Sprintf(`"%x"`, md5.Sum(body)); got != want {
+		t.Errorf("got: %v, want: %v", got, want)
+	}
+
+	w = httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Range", "bytes=0-4")
+	ETag()(handler).ServeHTTP(w, r)
+	if got, want := w.Code, http.StatusPartialContent; got != want {
+		t.Errorf("got: %v, want: %v", got, want)
+	}
+	if got, want := w.Header().Get("ETag"), ""; got != want {
+		t.Errorf("got: %v, want: %v", got, want)
+	}
+	if got, want := w.Body.String(), "hello"; got != want {
+		t.Errorf("got: %v, want: %v", got, want)
+	}
+}
@@ -0,0 +1,102 @@
+package umbrella
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestFetchMetadata(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	testCases := []struct {
+		method string
+		path   string
+		site   string
+		mode   string
+		dest   string
+		allow  bool
+	}{
+		{method: http.MethodPost, path: "/", allow: true},
+		{method: http.MethodPost, path: "/", site: "same-origin", mode: "cors", dest: "empty", allow: true},
+		{method: http.MethodPost, path: "/", site: "same-site", mode: "no-cors", dest: "image", allow: true},
+		{method: http.MethodGet, path: "/", site: "none", mode: "navigate", dest: "document", allow: true},
+		{method: http.MethodGet, path: "/", site: "cross-site", mode: "navigate", dest: "document", allow: true},
+		{method: http.MethodGet, path: "/", site: "cross-site", mode: "navigate", dest: "iframe", allow: true},
+		{method: http.MethodGet, path: "/", site: "cross-site", mode: "navigate", dest: "embed", allow: false},
+		{method: http.MethodGet, path: "/", site: "cross-site", mode: "navigate", dest: "object", allow: false},
+		{method: http.MethodPost, path: "/", site: "cross-site", mode: "navigate", dest: "document", allow: false},
+		{method: http.MethodGet, path: "/", site: "cross-site", mode: "no-cors", dest: "script", allow: false},
+		{method: http.MethodPost, path: "/", site: "cross-site", mode: "cors", dest: "empty", allow: false},
+		{method: http.MethodGet, path: "/", site: "unknown", mode: "cors", dest: "empty", allow: false},
+		{method: http.MethodPost, path: "/webhooks/github", site: "cross-site", mode: "cors", dest: "empty", allow: true},
+		{method: http.MethodPost, path: "/webhooks", site: "cross-site", mode: "cors", dest: "empty", allow: false},
+		{method: http.MethodGet, path: "/images", site: "cross-site", mode: "no-cors", dest: "image", allow: true},
+		{method: http.MethodGet, path: "/images/a.png", site: "cross-site", mode: "no-cors", dest: "image", allow: false},
+		{method: http.MethodOptions, path: "/", site: "cross-site", mode: "cors", dest: "empty", allow: true},
+	}
+	newRequest := func(method, path, site, mode, dest string) *http.Request {
+		r := httptest.NewRequest(method, path, nil)
+		for name, value := range map[string]string{"Sec-Fetch-Site": site, "Sec-Fetch-Mode": mode, "Sec-Fetch-Dest": dest} {
+			if value != "" {
+				r.Header.Set(name, value)
+			}
+		}
+		return r
+	}
+	cfg := FetchMetadataConfig{
+		ExemptPaths:   []string{"/webhooks/", "/images"},
+		ExemptMethods: []string{http.MethodOptions},
+	}
+
+	t.Run("case=enforce", func(t *testing.T) {
+		mw := FetchMetadata(cfg)(handler)
+		for _, tc := range testCases {
+			w := httptest.NewRecorder()
+			mw.ServeHTTP(w, newRequest(tc.method, tc.path, tc.site, tc.mode, tc.dest))
+			want := http.StatusForbidden
+			if tc.allow {
+				want = http.StatusOK
+			}
+			if got := w.Code; got != want {
+				t.Errorf("%+v: got: %v, want: %v", tc, got, want)
+			}
+		}
+
+		w := httptest.NewRecorder()
+		mw.ServeHTTP(w, newRequest(http.MethodGet, "/", "same-origin", "cors", "empty"))
+		if got, want := strings.Join(w.Header().Values("Vary"), ", "), "Sec-Fetch-Site, Sec-Fetch-Mode, Sec-Fetch-Dest"; got != want {
+			t.Errorf("got: %v, want: %v", got, want)
+		}
+	})
+
+	t.Run("case=report-only", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		log.SetOutput(buf)
+		defer log.SetOutput(os.Stderr)
+
+		cfg := cfg
+		cfg.ReportOnly = true
+		mw := FetchMetadata(cfg)(handler)
+		violations := 0
+		for _, tc := range testCases {
+			w := httptest.NewRecorder()
+			mw.ServeHTTP(w, newRequest(tc.method, tc.path, tc.site, tc.mode, tc.dest))
+			if got, want := w.Code, http.StatusOK; got != want {
+				t.Errorf("%+v: got: %v, want: %v", tc, got, want)
+			}
+			if !tc.allow {
+				violations++
+			}
+		}
+		if got, want := strings.Count(buf.String(), "fetchmetadata.violation"), violations; got != want {
+			t.Errorf("got: %v, want: %v", got, want)
+		}
+	})
+}
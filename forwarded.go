@@ -0,0 +1,110 @@
+package umbrella
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// ForwardedElement is an element of the Forwarded header (RFC 7239).
+// Each proxy appends an element that describes the request it received.
+type ForwardedElement struct {
+	// For identifies the node that made the request to the proxy, such as
+	// "192.0.2.60", "[2001:db8:cafe::17]:4711", "unknown" or "_hidden".
+	For string
+	// By identifies the interface where the request came in to the proxy.
+	By string
+	// Host is the Host request header as received by the proxy.
+	Host string
+	// Proto is the scheme used to make the request to the proxy.
+	Proto string
+}
+
+// IP returns the address of the For node or nil if it is obfuscated or
+// unknown.
+func (e ForwardedElement) IP() net.IP {
+	return forwardedNodeIP(e.For)
+}
+
+// ParseForwarded parses the value of the Forwarded header. Multiple header
+// lines can be joined with commas.
+// It returns an error if the value is malformed, in which case the header
+// cannot be trusted at all.
+func ParseForwarded(value string) ([]ForwardedElement, error) {
+	var elements []ForwardedElement
+	for _, element := range splitHeaderList(value, ',') {
+		var e ForwardedElement
+		seen := make(map[string]bool)
+		for _, pair := range splitHeaderList(element, ';') {
+			i := strings.IndexByte(pair, '=')
+			if i <= 0 {
+				return nil, fmt.Errorf("umbrella: invalid forwarded-pair: %q", pair)
+			}
+			name := strings.ToLower(strings.TrimSpace(pair[:i]))
+			v := strings.TrimSpace(pair[i+1:])
+			if !isForwardedValue(v) {
+				return nil, fmt.Errorf("umbrella: invalid forwarded-pair: %q", pair)
+			}
+			if seen[name] {
+				return nil, fmt.Errorf("umbrella: duplicate forwarded-pair: %q", name)
+			}
+			seen[name] = true
+			v = unquoteHeaderValue(v)
+			switch name {
+			case "for":
+				e.For = v
+			case "by":
+				e.By = v
+			case "host":
+				e.Host = v
+			case "proto":
+				e.Proto = strings.ToLower(v)
+			}
+		}
+		elements = append(elements, e)
+	}
+	return elements, nil
+}
+
+// isForwardedValue reports whether v is a token or a complete quoted-string.
+func isForwardedValue(v string) bool {
+	if v == "" {
+		return false
+	}
+	if v[0] != '"' {
+		return !strings.ContainsAny(v, "\" \t")
+	}
+	for i := 1; i < len(v); i++ {
+		switch v[i] {
+		case '\\':
+			i++
+		case '"':
+			return i == len(v)-1
+		}
+	}
+	return false
+}
+
+// forwardedNodeIP returns the address of a node identifier, which may be
+// followed by a port and has IPv6 addresses in brackets.
+func forwardedNodeIP(node string) net.IP {
+	if strings.HasPrefix(node, "[") {
+		i := strings.IndexByte(node, ']')
+		if i < 0 {
+			return nil
+		}
+		ip := net.ParseIP(node[1:i])
+		if ip == nil || ip.To4() != nil {
+			return nil
+		}
+		return ip
+	}
+	if i := strings.IndexByte(node, ':'); i >= 0 {
+		node = node[:i]
+	}
+	ip := net.ParseIP(node)
+	if ip == nil || ip.To4() == nil {
+		return nil
+	}
+	return ip
+}
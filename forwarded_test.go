@@ -0,0 +1,67 @@
+package umbrella
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseForwarded(t *testing.T) {
+	testCases := []struct {
+		value string
+		want  []ForwardedElement
+		ip    []string
+		err   bool
+	}{
+		{
+			value: `for=192.0.2.60;proto=HTTP;by=203.0.113.43`,
+			want:  []ForwardedElement{{For: "192.0.2.60", Proto: "http", By: "203.0.113.43"}},
+			ip:    []string{"192.0.2.60"},
+		},
+		{
+			value: `For="[2001:db8:cafe::17]:4711"; host="example.com:8080"`,
+			want:  []ForwardedElement{{For: "[2001:db8:cafe::17]:4711", Host: "example.com:8080"}},
+			ip:    []string{"2001:db8:cafe::17"},
+		},
+		{
+			value: `for=192.0.2.43, for="_hidden", for=unknown, for="198.51.100.17:80"`,
+			want: []ForwardedElement{
+				{For: "192.0.2.43"},
+				{For: "_hidden"},
+				{For: "unknown"},
+				{For: "198.51.100.17:80"},
+			},
+			ip: []string{"192.0.2.43", "<nil>", "<nil>", "198.51.100.17"},
+		},
+		{
+			value: `for="\"quoted\", value";proto=https`,
+			want:  []ForwardedElement{{For: `"quoted", value`, Proto: "https"}},
+			ip:    []string{"<nil>"},
+		},
+		{value: `for`, err: true},
+		{value: `=192.0.2.60`, err: true},
+		{value: `for=192.0.2.60;for=192.0.2.61`, err: true},
+		{value: `for="192.0.2.60, for=192.0.2.61`, err: true},
+		{value: `for=[2001:db8::1]:80 x`, err: true},
+	}
+	for _, tc := range testCases {
+		got, err := ParseForwarded(tc.value)
+		if tc.err {
+			if err == nil {
+				t.Errorf("%s: got: nil, want: error", tc.value)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tc.value, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got: %v, want: %v", tc.value, got, tc.want)
+		}
+		for i, e := range got {
+			if got, want := e.IP().String(), tc.ip[i]; got != want {
+				t.Errorf("%s: got: %v, want: %v", tc.value, got, want)
+			}
+		}
+	}
+}
@@ -86,3 +86,69 @@ func AddHeaderFunc(key, value string) HeaderFunc {
 		header.Set(key, value)
 	}
 }
+
+// addVary adds the names to the Vary header, skipping those that are
+// already listed.
+func addVary(header http.Header, names ...string) {
+	for _, name := range names {
+		found := false
+		for _, line := range header.Values("Vary") {
+			for _, v := range splitHeaderList(line, ',') {
+				if v == "*" || strings.EqualFold(v, name) {
+					found = true
+				}
+			}
+		}
+		if !found {
+			header.Add("Vary", name)
+		}
+	}
+}
+
+// splitHeaderList splits the value of a header at sep, ignoring separators
+// that appear inside quoted strings. Empty elements are dropped and the
+// remaining elements are trimmed of surrounding whitespace.
+func splitHeaderList(value string, sep byte) []string {
+	var list []string
+	var quoted, escaped bool
+	start := 0
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; {
+		case escaped:
+			escaped = false
+		case quoted && c == '\\':
+			escaped = true
+		case c == '"':
+			quoted = !quoted
+		case !quoted && c == sep:
+			if v := strings.TrimSpace(value[start:i]); v != "" {
+				list = append(list, v)
+			}
+			start = i + 1
+		}
+	}
+	if v := strings.TrimSpace(value[start:]); v != "" {
+		list = append(list, v)
+	}
+	return list
+}
+
+// unquoteHeaderValue removes the quotes and escapes from a quoted-string.
+// Values that are not quoted are returned as is.
+func unquoteHeaderValue(value string) string {
+	if len(value) < 2 || value[0] != '"' || value[len(value)-1] != '"' {
+		return value
+	}
+	value = value[1 : len(value)-1]
+	if !strings.Contains(value, `\`) {
+		return value
+	}
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' && i+1 < len(value) {
+			i++
+		}
+		b.WriteByte(value[i])
+	}
+	return b.String()
+}
@@ -1,6 +1,7 @@
 package umbrella
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 )
@@ -23,3 +24,67 @@ func HSTSHeaderFunc(maxAge int, opt string) HeaderFunc {
 	}
 	return AddHeaderFunc("Strict-Transport-Security", value)
 }
+
+// hstsPreloadMinMaxAge is the minimum max-age required by hstspreload.org.
+const hstsPreloadMinMaxAge = 31536000 // 365 days
+
+// HSTSConfig is the configuration for HSTSWithConfig.
+type HSTSConfig struct {
+	// MaxAge is the number of seconds that the browser uses only HTTPS for
+	// the host. Zero tells the browser to forget the host.
+	MaxAge int
+	// IncludeSubDomains applies the policy to all subdomains.
+	IncludeSubDomains bool
+	// Preload asks to be included in the HSTS preload list of browsers,
+	// which requires a max-age of at least one year and includeSubDomains.
+	Preload bool
+}
+
+// Validate returns an error if the max-age is negative, or if Preload is
+// set without meeting the requirements of hstspreload.org.
+func (cfg HSTSConfig) Validate() error {
+	if cfg.MaxAge < 0 {
+		return fmt.Errorf("umbrella: negative HSTS max-age: %d", cfg.MaxAge)
+	}
+	if cfg.Preload {
+		if cfg.MaxAge < hstsPreloadMinMaxAge {
+			return fmt.Errorf("umbrella: HSTS preload requires a max-age of at least %d: %d", hstsPreloadMinMaxAge, cfg.MaxAge)
+		}
+		if !cfg.IncludeSubDomains {
+			return errors.New("umbrella: HSTS preload requires includeSubDomains")
+		}
+	}
+	return nil
+}
+
+// String returns the value of the Strict-Transport-Security header.
+func (cfg HSTSConfig) String() string {
+	value := fmt.Sprintf("max-age=%d", cfg.MaxAge)
+	if cfg.IncludeSubDomains {
+		value += "; includeSubDomains"
+	}
+	if cfg.Preload {
+		value += "; preload"
+	}
+	return value
+}
+
+// HSTSWithConfig adds the Strict-Transport-Security header to responses to
+// HTTPS requests. Browsers ignore the header received over HTTP, as
+// required by RFC 6797. The scheme is taken from the ClientInfo of the
+// request. It panics if the configuration is invalid.
+func HSTSWithConfig(cfg HSTSConfig) func(http.Handler) http.Handler {
+	if err := cfg.Validate(); err != nil {
+		panic(err.Error())
+	}
+	value := cfg.String()
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if GetClientInfo(r).Scheme == "https" {
+				w.Header().Set("Strict-Transport-Security", value)
+			}
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
@@ -2,6 +2,7 @@ package umbrella
 
 import (
 	"net/http"
+	"net/http/httptest"
 	"testing"
 )
 
@@ -55,3 +56,66 @@ func TestHSTS(t *testing.T) {
 		_ = resp.Body.Close()
 	})
 }
+
+func TestHSTSWithConfig(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	t.Run("case=header", func(t *testing.T) {
+		testCases := []struct {
+			cfg    HSTSConfig
+			target string
+			want   string
+		}{
+			{cfg: HSTSConfig{MaxAge: 63072000, IncludeSubDomains: true, Preload: true}, target: "https://example.com/", want: "max-age=63072000; includeSubDomains; preload"},
+			{cfg: HSTSConfig{MaxAge: 60, IncludeSubDomains: true}, target: "https://example.com/", want: "max-age=60; includeSubDomains"},
+			{cfg: HSTSConfig{}, target: "https://example.com/", want: "max-age=0"},
+			{cfg: HSTSConfig{MaxAge: 60}, target: "http://example.com/", want: ""},
+		}
+		for _, tc := range testCases {
+			w := httptest.NewRecorder()
+			HSTSWithConfig(tc.cfg)(handler).ServeHTTP(w, httptest.NewRequest(http.MethodGet, tc.target, nil))
+			if got, want := w.Header().Get("Strict-Transport-Security"), tc.want; got != want {
+				t.Errorf("got: %v, want: %v", got, want)
+			}
+		}
+	})
+
+	t.Run("case=forwarded", func(t *testing.T) {
+		cidrs, _ := ParseCIDRs("192.0.2.1")
+		mw := RealIPWithConfig(RealIPConfig{TrustedProxies: cidrs, Headers: []string{"Forwarded"}})(HSTSWithConfig(HSTSConfig{MaxAge: 60})(handler))
+		r := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+		r.RemoteAddr = "192.0.2.1:1234"
+		r.Header.Set("Forwarded", "for=198.51.100.1;proto=https")
+		w := httptest.NewRecorder()
+		mw.ServeHTTP(w, r)
+		if got, want := w.Header().Get("Strict-Transport-Security"), "max-age=60"; got != want {
+			t.Errorf("got: %v, want: %v", got, want)
+		}
+	})
+
+	t.Run("case=validate", func(t *testing.T) {
+		testCases := []struct {
+			cfg   HSTSConfig
+			valid bool
+		}{
+			{cfg: HSTSConfig{MaxAge: 31536000, IncludeSubDomains: true, Preload: true}, valid: true},
+			{cfg: HSTSConfig{MaxAge: 300}, valid: true},
+			{cfg: HSTSConfig{MaxAge: -1}, valid: false},
+			{cfg: HSTSConfig{MaxAge: 31535999, IncludeSubDomains: true, Preload: true}, valid: false},
+			{cfg: HSTSConfig{MaxAge: 31536000, Preload: true}, valid: false},
+		}
+		for _, tc := range testCases {
+			if got, want := tc.cfg.Validate() == nil, tc.valid; got != want {
+				t.Errorf("%+v: got: %v, want: %v", tc.cfg, got, want)
+			}
+		}
+		defer func() {
+			if recover() == nil {
+				t.Error("expected panic")
+			}
+		}()
+		HSTSWithConfig(HSTSConfig{MaxAge: 60, Preload: true})
+	})
+}
@@ -0,0 +1,84 @@
+package umbrella
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// acmeChallengePath is the path prefix of ACME HTTP-01 challenges, which
+// must be served over HTTP.
+const acmeChallengePath = "/.well-known/acme-challenge/"
+
+// HTTPSRedirectConfig is the configuration for HTTPSRedirect.
+type HTTPSRedirectConfig struct {
+	// CanonicalHost is the host that requests are redirected to, such as
+	// "www.example.com". If empty, the host of the request is kept.
+	CanonicalHost string
+	// StatusCode is the status of redirects, which is 301, 302, 307 or 308.
+	// The default is 301 Moved Permanently. Use 308 Permanent Redirect to
+	// keep the method and the body of POST requests.
+	StatusCode int
+	// ExemptPaths is the list of paths that are not redirected, in addition
+	// to ACME challenges. Paths ending with "/" match all paths under them.
+	ExemptPaths []string
+}
+
+// HTTPSRedirect is middleware that redirects HTTP requests to HTTPS, and
+// requests for other hosts to the canonical host, keeping the path and the
+// query. The scheme and the host are taken from the ClientInfo of the
+// request, which is set from the TLS state of the connection or by
+// RealIPWithConfig from the headers of trusted proxies. The port is removed
+// from the host when the scheme changes. ACME HTTP-01 challenges under
+// /.well-known/acme-challenge/ are not redirected.
+// It panics if the status code is not a redirect status.
+func HTTPSRedirect(cfg HTTPSRedirectConfig) func(http.Handler) http.Handler {
+	switch cfg.StatusCode {
+	case 0:
+		cfg.StatusCode = http.StatusMovedPermanently
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+	default:
+		panic(fmt.Sprintf("umbrella: invalid redirect status: %d", cfg.StatusCode))
+	}
+	exempt := append([]string{acmeChallengePath}, cfg.ExemptPaths...)
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			for _, p := range exempt {
+				if r.URL.Path == p || (strings.HasSuffix(p, "/") && strings.HasPrefix(r.URL.Path, p)) {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+			info := GetClientInfo(r)
+			host := info.Host
+			if info.Scheme != "https" {
+				host = stripPort(host)
+			}
+			if cfg.CanonicalHost != "" {
+				host = cfg.CanonicalHost
+			}
+			if info.Scheme == "https" && strings.EqualFold(host, info.Host) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if host == "" {
+				http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+				return
+			}
+			http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), cfg.StatusCode)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// stripPort returns the host without the port.
+func stripPort(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		if strings.Contains(h, ":") {
+			return "[" + h + "]"
+		}
+		return h
+	}
+	return host
+}
@@ -0,0 +1,89 @@
+package umbrella
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHTTPSRedirect(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	t.Run("case=redirect", func(t *testing.T) {
+		testCases := []struct {
+			cfg      HTTPSRedirectConfig
+			method   string
+			target   string
+			code     int
+			location string
+		}{
+			{target: "http://example.com/a/b?q=1&r=%20", code: http.StatusMovedPermanently, location: "https://example.com/a/b?q=1&r=%20"},
+			{target: "http://example.com:8080/", code: http.StatusMovedPermanently, location: "https://example.com/"},
+			{target: "http://[::1]:8080/", code: http.StatusMovedPermanently, location: "https://[::1]/"},
+			{target: "https://example.com/", code: http.StatusOK},
+			{target: "https://example.com:8443/", code: http.StatusOK},
+			{target: "http://example.com/.well-known/acme-challenge/token", code: http.StatusOK},
+			{cfg: HTTPSRedirectConfig{StatusCode: http.StatusPermanentRedirect}, method: http.MethodPost, target: "http://example.com/form", code: http.StatusPermanentRedirect, location: "https://example.com/form"},
+			{cfg: HTTPSRedirectConfig{CanonicalHost: "www.example.com"}, target: "https://example.com/a?q=1", code: http.StatusMovedPermanently, location: "https://www.example.com/a?q=1"},
+			{cfg: HTTPSRedirectConfig{CanonicalHost: "www.example.com"}, target: "http://example.com/a", code: http.StatusMovedPermanently, location: "https://www.example.com/a"},
+			{cfg: HTTPSRedirectConfig{CanonicalHost: "www.example.com"}, target: "http://www.example.com/a", code: http.StatusMovedPermanently, location: "https://www.example.com/a"},
+			{cfg: HTTPSRedirectConfig{CanonicalHost: "www.example.com"}, target: "https://WWW.example.com/a", code: http.StatusOK},
+			{cfg: HTTPSRedirectConfig{CanonicalHost: "www.example.com"}, target: "http://example.com/.well-known/acme-challenge/token", code: http.StatusOK},
+			{cfg: HTTPSRedirectConfig{ExemptPaths: []string{"/healthz"}}, target: "http://example.com/healthz", code: http.StatusOK},
+			{cfg: HTTPSRedirectConfig{ExemptPaths: []string{"/healthz"}}, target: "http://example.com/healthz/x", code: http.StatusMovedPermanently, location: "https://example.com/healthz/x"},
+		}
+		for _, tc := range testCases {
+			method := tc.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			w := httptest.NewRecorder()
+			HTTPSRedirect(tc.cfg)(handler).ServeHTTP(w, httptest.NewRequest(method, tc.target, nil))
+			if got, want := w.Code, tc.code; got != want {
+				t.Errorf("%s: got: %v, want: %v", tc.target, got, want)
+			}
+			if got, want := w.Header().Get("Location"), tc.location; got != want {
+				t.Errorf("%s: got: %v, want: %v", tc.target, got, want)
+			}
+		}
+	})
+
+	t.Run("case=forwarded", func(t *testing.T) {
+		cidrs, _ := ParseCIDRs("192.0.2.1")
+		mw := RealIPWithConfig(RealIPConfig{TrustedProxies: cidrs, Headers: []string{"Forwarded"}})(HTTPSRedirect(HTTPSRedirectConfig{})(handler))
+		testCases := []struct {
+			remoteAddr string
+			forwarded  string
+			code       int
+			location   string
+		}{
+			{remoteAddr: "192.0.2.1:1234", forwarded: "for=198.51.100.1;proto=https;host=example.com", code: http.StatusOK},
+			{remoteAddr: "192.0.2.1:1234", forwarded: "for=198.51.100.1;proto=http;host=example.com", code: http.StatusMovedPermanently, location: "https://example.com/"},
+			{remoteAddr: "203.0.113.1:1234", forwarded: "for=198.51.100.1;proto=https;host=example.com", code: http.StatusMovedPermanently, location: "https://internal/"},
+		}
+		for _, tc := range testCases {
+			r := httptest.NewRequest(http.MethodGet, "http://internal/", nil)
+			r.RemoteAddr = tc.remoteAddr
+			r.Header.Set("Forwarded", tc.forwarded)
+			w := httptest.NewRecorder()
+			mw.ServeHTTP(w, r)
+			if got, want := w.Code, tc.code; got != want {
+				t.Errorf("%+v: got: %v, want: %v", tc, got, want)
+			}
+			if got, want := w.Header().Get("Location"), tc.location; got != want {
+				t.Errorf("%+v: got: %v, want: %v", tc, got, want)
+			}
+		}
+	})
+
+	t.Run("case=invalid", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected panic")
+			}
+		}()
+		HTTPSRedirect(HTTPSRedirectConfig{StatusCode: http.StatusOK})
+	})
+}
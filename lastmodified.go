@@ -0,0 +1,97 @@
+package umbrella
+
+import (
+	"log"
+	"net/http"
+	"time"
+)
+
+// LastModified provides middleware that sets the Last-Modified header and
+// answers the If-Modified-Since and If-Unmodified-Since headers with it.
+// If lookup returns a non-zero time, the preconditions are evaluated before
+// the handler runs, so 304 Not Modified and 412 Precondition Failed are
+// returned without executing the handler. Otherwise the Last-Modified header
+// set by the handler is used and its body is discarded. Requests with
+// If-Match or If-None-Match are left to the handler, because entity-tags
+// take precedence over dates.
+// lookup may be nil.
+func LastModified(lookup func(r *http.Request) (time.Time, error)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			var lastModified time.Time
+			if lookup != nil {
+				t, err := lookup(r)
+				if err != nil {
+					log.Printf("lastmodified.error: %v", err)
+					http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+					return
+				}
+				lastModified = t
+			}
+			conditional := r.Header.Get("If-Match") == "" && r.Header.Get("If-None-Match") == ""
+			if !lastModified.IsZero() {
+				w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+				if conditional {
+					if code := CheckPreconditions(r, "", lastModified); code != 0 {
+						writePreconditionStatus(w, code)
+						return
+					}
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+			if !conditional || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			next.ServeHTTP(&lastModifiedWriter{ResponseWriter: w, r: r}, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// lastModifiedWriter evaluates the preconditions with the Last-Modified
+// header set by the handler.
+type lastModifiedWriter struct {
+	http.ResponseWriter
+	r           *http.Request
+	wroteHeader bool
+	discard     bool
+}
+
+func (w *lastModifiedWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	if code >= 200 && code < 300 {
+		if lastModified := lastModifiedTime(w.Header()); !lastModified.IsZero() {
+			if code := CheckPreconditions(w.r, "", lastModified); code != 0 {
+				w.discard = true
+				writePreconditionStatus(w.ResponseWriter, code)
+				return
+			}
+		}
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *lastModifiedWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.discard {
+		return len(p), nil
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// Flush implements the http.Flusher.
+func (w *lastModifiedWriter) Flush() {
+	if w.discard {
+		return
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
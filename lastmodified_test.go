@@ -0,0 +1,103 @@
+package umbrella
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLastModified(t *testing.T) {
+	modified := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+	before := modified.Add(-time.Hour).Format(http.TimeFormat)
+	after := modified.Add(time.Hour).Format(http.TimeFormat)
+
+	t.Run("case=lookup", func(t *testing.T) {
+		calls := 0
+		lookup := func(r *http.Request) (time.Time, error) {
+			switch r.URL.Path {
+			case "/error":
+				return time.Time{}, errors.New("error")
+			case "/unknown":
+				return time.Time{}, nil
+			}
+			return modified, nil
+		}
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			_, _ = w.Write([]byte("body"))
+		})
+		mw := LastModified(lookup)
+
+		testCases := []struct {
+			method string
+			path   string
+			header map[string]string
+			code   int
+			calls  int
+		}{
+			{method: http.MethodGet, path: "/", code: http.StatusOK, calls: 1},
+			{method: http.MethodGet, path: "/", header: map[string]string{"If-Modified-Since": after}, code: http.StatusNotModified},
+			{method: http.MethodHead, path: "/", header: map[string]string{"If-Modified-Since": modified.Format(http.TimeFormat)}, code: http.StatusNotModified},
+			{method: http.MethodGet, path: "/", header: map[string]string{"If-Modified-Since": before}, code: http.StatusOK, calls: 1},
+			{method: http.MethodPut, path: "/", header: map[string]string{"If-Unmodified-Since": before}, code: http.StatusPreconditionFailed},
+			{method: http.MethodPut, path: "/", header: map[string]string{"If-Unmodified-Since": after}, code: http.StatusOK, calls: 1},
+			{method: http.MethodGet, path: "/", header: map[string]string{"If-Modified-Since": after, "If-None-Match": `"a"`}, code: http.StatusOK, calls: 1},
+			{method: http.MethodGet, path: "/unknown", header: map[string]string{"If-Modified-Since": after}, code: http.StatusOK, calls: 1},
+			{method: http.MethodGet, path: "/error", code: http.StatusInternalServerError},
+		}
+		for _, tc := range testCases {
+			calls = 0
+			r := httptest.NewRequest(tc.method, tc.path, nil)
+			for k, v := range tc.header {
+				r.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			mw(handler).ServeHTTP(w, r)
+			if got, want := w.Code, tc.code; got != want {
+				t.Errorf("%s %s %v: got: %v, want: %v", tc.method, tc.path, tc.header, got, want)
+			}
+			if got, want := calls, tc.calls; got != want {
+				t.Errorf("%s %s %v: got: %v, want: %v", tc.method, tc.path, tc.header, got, want)
+			}
+			if tc.code != http.StatusInternalServerError && tc.path == "/" {
+				if got, want := w.Header().Get("Last-Modified"), modified.Format(http.TimeFormat); got != want {
+					t.Errorf("got: %v, want: %v", got, want)
+				}
+			}
+		}
+	})
+
+	t.Run("case=handler", func(t *testing.T) {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Last-Modified", modified.Format(http.TimeFormat))
+			_, _ = w.Write([]byte("body"))
+		})
+		mw := LastModified(nil)
+
+		testCases := []struct {
+			since string
+			code  int
+			body  string
+		}{
+			{code: http.StatusOK, body: "body"},
+			{since: after, code: http.StatusNotModified},
+			{since: before, code: http.StatusOK, body: "body"},
+		}
+		for _, tc := range testCases {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.since != "" {
+				r.Header.Set("If-Modified-Since", tc.since)
+			}
+			w := httptest.NewRecorder()
+			mw(handler).ServeHTTP(w, r)
+			if got, want := w.Code, tc.code; got != want {
+				t.Errorf("If-Modified-Since=%s: got: %v, want: %v", tc.since, got, want)
+			}
+			if got, want := w.Body.String(), tc.body; got != want {
+				t.Errorf("If-Modified-Since=%s: got: %v, want: %v", tc.since, got, want)
+			}
+		}
+	})
+}
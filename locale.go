@@ -0,0 +1,174 @@
+package umbrella
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// LocaleConfig is the configuration for Locale.
+type LocaleConfig struct {
+	// Locales is the list of supported language tags, such as "en" or
+	// "pt-BR". The first one is used if no locale matches.
+	Locales []string
+	// Cookie is the name of the cookie that overrides Accept-Language.
+	Cookie string
+	// QueryParam is the name of the query parameter that overrides
+	// Accept-Language and the cookie.
+	QueryParam string
+}
+
+type localeKey struct{}
+
+// Locale is middleware that selects the locale of the response from the
+// supported locales and stores it in the request context. The locale is
+// available with GetLocale and is set to the Content-Language header.
+// The query parameter and the cookie, if configured and supported, take
+// precedence over the Accept-Language header, which is matched with
+// MatchLocale. The Vary header lists Accept-Language and Cookie.
+// It panics if no locale is given.
+func Locale(cfg LocaleConfig) func(http.Handler) http.Handler {
+	if len(cfg.Locales) == 0 {
+		panic("umbrella: no locales")
+	}
+	vary := []string{"Accept-Language"}
+	if cfg.Cookie != "" {
+		vary = append(vary, "Cookie")
+	}
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			locale := ""
+			if cfg.QueryParam != "" {
+				locale = lookupLocale(r.URL.Query().Get(cfg.QueryParam), cfg.Locales)
+			}
+			if locale == "" && cfg.Cookie != "" {
+				if c, err := r.Cookie(cfg.Cookie); err == nil {
+					locale = lookupLocale(c.Value, cfg.Locales)
+				}
+			}
+			if locale == "" {
+				locale = MatchLocale(strings.Join(r.Header.Values("Accept-Language"), ","), cfg.Locales...)
+			}
+			addVary(w.Header(), vary...)
+			w.Header().Set("Content-Language", locale)
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), localeKey{}, locale)))
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// GetLocale returns the locale selected by Locale for the request, or an
+// empty string if Locale has not been applied.
+func GetLocale(r *http.Request) string {
+	locale, _ := r.Context().Value(localeKey{}).(string)
+	return locale
+}
+
+// MatchLocale returns the supported locale that best matches the
+// Accept-Language header value. Language ranges are tried in order of
+// quality, and each range falls back to less specific tags (pt-BR to pt,
+// RFC 4647 3.4) and to more specific supported tags (pt to pt-BR). If no
+// range matches, a supported locale with the same primary language is used,
+// and then the first supported locale. Locales excluded with q=0 are never
+// selected by a fallback.
+func MatchLocale(acceptLanguage string, locales ...string) string {
+	if len(locales) == 0 {
+		return ""
+	}
+	ranges := parseQualityList(acceptLanguage)
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].q > ranges[j].q
+	})
+
+	var excluded []string
+	for _, rng := range ranges {
+		if rng.q == 0 {
+			excluded = append(excluded, normalizeLanguageTag(rng.value))
+		}
+	}
+	candidates := make([]string, 0, len(locales))
+	for _, locale := range locales {
+		tag := normalizeLanguageTag(locale)
+		ok := true
+		for _, ex := range excluded {
+			if ex == tag || hasLanguagePrefix(tag, ex) {
+				ok = false
+			}
+		}
+		if ok {
+			candidates = append(candidates, locale)
+		}
+	}
+
+	for _, rng := range ranges {
+		if rng.q == 0 {
+			continue
+		}
+		if rng.value == "*" {
+			break
+		}
+		if locale := lookupLocale(rng.value, candidates); locale != "" {
+			return locale
+		}
+	}
+	for _, rng := range ranges {
+		if rng.q == 0 || rng.value == "*" {
+			continue
+		}
+		primary := strings.SplitN(normalizeLanguageTag(rng.value), "-", 2)[0]
+		for _, locale := range candidates {
+			if strings.SplitN(normalizeLanguageTag(locale), "-", 2)[0] == primary {
+				return locale
+			}
+		}
+	}
+	if len(candidates) != 0 {
+		return candidates[0]
+	}
+	return locales[0]
+}
+
+// lookupLocale returns the supported locale that matches the tag exactly,
+// after removing subtags from the end, or as a prefix.
+func lookupLocale(tag string, locales []string) string {
+	tag = normalizeLanguageTag(tag)
+	if tag == "" {
+		return ""
+	}
+	for t := tag; t != ""; {
+		for _, locale := range locales {
+			if normalizeLanguageTag(locale) == t {
+				return locale
+			}
+		}
+		i := strings.LastIndexByte(t, '-')
+		if i < 0 {
+			break
+		}
+		t = t[:i]
+		// Single-letter subtags, such as "x" of private use, are removed with
+		// the following subtag (RFC 4647 3.4).
+		if i >= 2 && t[i-2] == '-' {
+			t = t[:i-2]
+		}
+	}
+	for _, locale := range locales {
+		if hasLanguagePrefix(normalizeLanguageTag(locale), tag) {
+			return locale
+		}
+	}
+	return ""
+}
+
+// normalizeLanguageTag lowercases the tag and replaces underscores, so that
+// tags can be compared.
+func normalizeLanguageTag(tag string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(tag), "_", "-"))
+}
+
+// hasLanguagePrefix reports whether the normalized tag starts with the
+// subtags of prefix.
+func hasLanguagePrefix(tag, prefix string) bool {
+	return len(tag) > len(prefix) && tag[len(prefix)] == '-' && tag[:len(prefix)] == prefix
+}
@@ -0,0 +1,98 @@
+package umbrella
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestLocale(t *testing.T) {
+	var got string
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = GetLocale(r)
+		w.WriteHeader(http.StatusOK)
+	})
+	mw := Locale(LocaleConfig{
+		Locales:    []string{"en", "ja", "pt-BR"},
+		Cookie:     "lang",
+		QueryParam: "lang",
+	})
+
+	testCases := []struct {
+		name   string
+		target string
+		header map[string]string
+		want   string
+	}{
+		{name: "default", target: "/", want: "en"},
+		{name: "Accept-Language", target: "/", header: map[string]string{"Accept-Language": "ja-JP, en;q=0.8"}, want: "ja"},
+		{name: "fallback", target: "/", header: map[string]string{"Accept-Language": "pt-PT, fr;q=0.5"}, want: "pt-BR"},
+		{name: "cookie", target: "/", header: map[string]string{"Accept-Language": "ja", "Cookie": "lang=pt_br"}, want: "pt-BR"},
+		{name: "unsupported cookie", target: "/", header: map[string]string{"Accept-Language": "ja", "Cookie": "lang=fr"}, want: "ja"},
+		{name: "query", target: "/?lang=ja", header: map[string]string{"Cookie": "lang=pt-BR"}, want: "ja"},
+		{name: "unsupported query", target: "/?lang=fr", header: map[string]string{"Cookie": "lang=pt-BR"}, want: "pt-BR"},
+	}
+	for _, tc := range testCases {
+		t.Run("case="+tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tc.target, nil)
+			for k, v := range tc.header {
+				r.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			mw(handler).ServeHTTP(w, r)
+			if got != tc.want {
+				t.Errorf("got: %v, want: %v", got, tc.want)
+			}
+			if got, want := w.Header().Get("Content-Language"), tc.want; got != want {
+				t.Errorf("got: %v, want: %v", got, want)
+			}
+			if got, want := w.Header().Values("Vary"), []string{"Accept-Language", "Cookie"}; !reflect.DeepEqual(got, want) {
+				t.Errorf("got: %v, want: %v", got, want)
+			}
+		})
+	}
+
+	t.Run("case=without middleware", func(t *testing.T) {
+		if got := GetLocale(httptest.NewRequest(http.MethodGet, "/", nil)); got != "" {
+			t.Errorf("got: %v, want: empty", got)
+		}
+	})
+
+	t.Run("case=no locales", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Error("got: nil, want: panic")
+			}
+		}()
+		Locale(LocaleConfig{})
+	})
+}
+
+func TestMatchLocale(t *testing.T) {
+	locales := []string{"en-US", "en-GB", "pt", "zh-Hant", "ja"}
+	testCases := []struct {
+		acceptLanguage string
+		want           string
+	}{
+		{acceptLanguage: "", want: "en-US"},
+		{acceptLanguage: "ja", want: "ja"},
+		{acceptLanguage: "EN-gb", want: "en-GB"},
+		{acceptLanguage: "pt-BR", want: "pt"},
+		{acceptLanguage: "en", want: "en-US"},
+		{acceptLanguage: "en-AU, ja;q=0.9", want: "ja"},
+		{acceptLanguage: "en-AU, fr;q=0.9", want: "en-US"},
+		{acceptLanguage: "zh-Hant-TW", want: "zh-Hant"},
+		{acceptLanguage: "ja-x-osaka", want: "ja"},
+		{acceptLanguage: "de, ja;q=0.1, pt;q=0.5", want: "pt"},
+		{acceptLanguage: "fr, *;q=0.5", want: "en-US"},
+		{acceptLanguage: "en, en-US;q=0", want: "en-GB"},
+		{acceptLanguage: "fr, en;q=0", want: "pt"},
+		{acceptLanguage: "invalid;q=x, ja", want: "ja"},
+	}
+	for _, tc := range testCases {
+		if got := MatchLocale(tc.acceptLanguage, locales...); got != tc.want {
+			t.Errorf("%q: got: %v, want: %v", tc.acceptLanguage, got, tc.want)
+		}
+	}
+}
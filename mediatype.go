@@ -0,0 +1,137 @@
+package umbrella
+
+import (
+	"fmt"
+	"mime"
+	"strconv"
+	"strings"
+)
+
+// mediaRange is a media type that may contain wildcards, such as
+// "text/*; charset=utf-8", with the quality value given in Accept.
+type mediaRange struct {
+	typ     string
+	subtype string
+	params  map[string]string
+	q       float64
+}
+
+// parseMediaRange parses a media type or a media range of the Accept header.
+func parseMediaRange(s string) (*mediaRange, error) {
+	mediaType, params, err := mime.ParseMediaType(s)
+	if err != nil {
+		return nil, err
+	}
+	i := strings.IndexByte(mediaType, '/')
+	if i <= 0 || i == len(mediaType)-1 {
+		return nil, fmt.Errorf("umbrella: invalid media type: %q", s)
+	}
+	m := &mediaRange{typ: mediaType[:i], subtype: mediaType[i+1:], params: params, q: 1}
+	if m.typ == "*" && m.subtype != "*" {
+		return nil, fmt.Errorf("umbrella: invalid media type: %q", s)
+	}
+	if v, ok := params["q"]; ok {
+		q, ok := parseQValue(v)
+		if !ok {
+			return nil, fmt.Errorf("umbrella: invalid quality value: %q", s)
+		}
+		m.q = q
+		delete(params, "q")
+	}
+	return m, nil
+}
+
+// mustParseMediaRanges parses the media types given to middleware.
+func mustParseMediaRanges(values []string) []*mediaRange {
+	list := make([]*mediaRange, len(values))
+	for i, v := range values {
+		m, err := parseMediaRange(v)
+		if err != nil {
+			panic(err)
+		}
+		list[i] = m
+	}
+	return list
+}
+
+// parseAccept parses the Accept header. Invalid elements are ignored.
+// A missing header is equivalent to "*/*".
+func parseAccept(value string) []*mediaRange {
+	if strings.TrimSpace(value) == "" {
+		return []*mediaRange{{typ: "*", subtype: "*", q: 1}}
+	}
+	var list []*mediaRange
+	for _, v := range splitHeaderList(value, ',') {
+		if m, err := parseMediaRange(v); err == nil {
+			list = append(list, m)
+		}
+	}
+	return list
+}
+
+// parseQValue parses a quality value (RFC 9110 12.4.2).
+func parseQValue(v string) (float64, bool) {
+	q, err := strconv.ParseFloat(v, 64)
+	if err != nil || q < 0 || q > 1 || len(v) > 5 {
+		return 0, false
+	}
+	return q, true
+}
+
+// contains reports whether t is in the range m. The parameters of m must be
+// present in t with the same values.
+func (m *mediaRange) contains(t *mediaRange) bool {
+	if m.typ != "*" && m.typ != t.typ {
+		return false
+	}
+	if m.subtype != "*" && m.subtype != t.subtype {
+		return false
+	}
+	for k, v := range m.params {
+		if !strings.EqualFold(t.params[k], v) {
+			return false
+		}
+	}
+	return true
+}
+
+// overlaps reports whether the ranges have a media type in common.
+func (m *mediaRange) overlaps(t *mediaRange) bool {
+	return m.contains(t) || t.contains(m)
+}
+
+// matches reports whether the range m of Accept matches the offered type t,
+// which may itself be a range.
+func (m *mediaRange) matches(t *mediaRange) bool {
+	if t.typ == "*" || t.subtype == "*" {
+		return m.overlaps(t)
+	}
+	return m.contains(t)
+}
+
+// specificity orders media ranges from "*/*" to types with parameters.
+func (m *mediaRange) specificity() int {
+	switch {
+	case m.typ == "*":
+		return 0
+	case m.subtype == "*":
+		return 1
+	}
+	return 2 + len(m.params)
+}
+
+// mediaTypeQuality returns the quality of the media type given by the most
+// specific range of accept that contains it (RFC 9110 12.5.1).
+func mediaTypeQuality(accept []*mediaRange, t *mediaRange) float64 {
+	q, specificity := 0.0, -1
+	for _, m := range accept {
+		if s := m.specificity(); s > specificity && m.matches(t) {
+			q, specificity = m.q, s
+		}
+	}
+	return q
+}
+
+func (m *mediaRange) String() string {
+	return mime.FormatMediaType(m.typ+"/"+m.subtype, m.params)
+}
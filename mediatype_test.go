@@ -0,0 +1,28 @@
+package umbrella
+
+import "testing"
+
+func TestMediaTypeQuality(t *testing.T) {
+	// The example of RFC 9110 12.5.1.
+	accept := parseAccept("text/*;q=0.3, text/plain;q=0.7, text/plain;format=flowed, text/plain;format=fixed;q=0.4, */*;q=0.5")
+	testCases := []struct {
+		mediaType string
+		q         float64
+	}{
+		{mediaType: "text/plain;format=flowed", q: 1},
+		{mediaType: "text/plain", q: 0.7},
+		{mediaType: "text/html", q: 0.3},
+		{mediaType: "image/jpeg", q: 0.5},
+		{mediaType: "text/plain;format=fixed", q: 0.4},
+		{mediaType: "text/html;level=3", q: 0.3},
+	}
+	for _, tc := range testCases {
+		m, err := parseMediaRange(tc.mediaType)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := mediaTypeQuality(accept, m), tc.q; got != want {
+			t.Errorf("%s: got: %v, want: %v", tc.mediaType, got, want)
+		}
+	}
+}
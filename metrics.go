@@ -34,6 +34,8 @@ type Metrics struct {
 	MethodCount      map[string]int64 `json:"methodCount"`
 	StatusCount      map[int]int64    `json:"statusCount"`
 	StatusClassCount map[string]int64 `json:"statusClassCount"`
+
+	ReportCount map[string]int64 `json:"reportCount"`
 }
 
 // Clone returns a new Metrics with the same value.
@@ -61,6 +63,7 @@ func (m *Metrics) Clone() *Metrics {
 		MethodCount:                      make(map[string]int64),
 		StatusCount:                      make(map[int]int64),
 		StatusClassCount:                 make(map[string]int64),
+		ReportCount:                      make(map[string]int64),
 	}
 	for k, v := range m.MethodCount {
 		m2.MethodCount[k] = v
@@ -71,6 +74,9 @@ func (m *Metrics) Clone() *Metrics {
 	for k, v := range m.StatusClassCount {
 		m2.StatusClassCount[k] = v
 	}
+	for k, v := range m.ReportCount {
+		m2.ReportCount[k] = v
+	}
 	return m2
 }
 
@@ -95,6 +101,7 @@ func newMetrics() *Metrics {
 			"4xx": 0,
 			"5xx": 0,
 		},
+		ReportCount: make(map[string]int64),
 	}
 	for i := 0; i < 600; i++ {
 		if http.StatusText(i) != "" {
@@ -18,6 +18,7 @@ type MetricsRecorder struct {
 	rwm *sync.RWMutex
 
 	requestMetricsHookFunc func(*RequestMetrics)
+	reportHookFunc         func(*Report)
 }
 
 // NewMetricsRecorder creates and returns a new MetricsRecorder.
@@ -26,6 +27,7 @@ func NewMetricsRecorder(opts ...MetricsRecorderOption) *MetricsRecorder {
 		m:                      newMetrics(),
 		rwm:                    new(sync.RWMutex),
 		requestMetricsHookFunc: func(rm *RequestMetrics) {},
+		reportHookFunc:         func(rep *Report) {},
 	}
 	for _, opt := range opts {
 		opt(mr)
@@ -151,6 +153,7 @@ func (mr *MetricsRecorder) Middleware() func(http.Handler) http.Handler {
 				Status:                      rec.Code,
 				UserAgent:                   r.UserAgent(),
 				Referer:                     r.Referer(),
+				ClientIP:                    clientIP(r),
 				GoroutinesCount:             goroutines,
 				RequestDurationNanoseconds:  ns,
 				RequestDurationMilliseconds: ms,
@@ -169,6 +172,21 @@ func (mr *MetricsRecorder) Middleware() func(http.Handler) http.Handler {
 	}
 }
 
+// RecordReport counts the report by its type and passes it to the hook
+// function set with WithReportHookFunc. Types other than those sent by
+// browsers, such as "csp-violation", "coep", "deprecation" and
+// "network-error", are counted as "other".
+func (mr *MetricsRecorder) RecordReport(rep *Report) {
+	typ := rep.Type
+	if !reportTypes[typ] {
+		typ = "other"
+	}
+	mr.rwm.Lock()
+	mr.m.ReportCount[typ]++
+	mr.rwm.Unlock()
+	mr.reportHookFunc(rep)
+}
+
 // Handler returns metrics in JSON.
 func (mr *MetricsRecorder) Handler(w http.ResponseWriter, r *http.Request) {
 	raw, _ := json.MarshalIndent(mr.m, "", "  ")
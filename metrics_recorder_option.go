@@ -12,3 +12,13 @@ func WithRequestMetricsHookFunc(fn func(*RequestMetrics)) MetricsRecorderOption
 		}
 	}
 }
+
+// WithReportHookFunc sets the hook function to be called for each report
+// recorded with RecordReport.
+func WithReportHookFunc(fn func(*Report)) MetricsRecorderOption {
+	return func(mr *MetricsRecorder) {
+		if fn != nil {
+			mr.reportHookFunc = fn
+		}
+	}
+}
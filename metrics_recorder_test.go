@@ -3,6 +3,7 @@ package umbrella
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
 	"reflect"
@@ -17,6 +18,9 @@ func TestMetricsRecorder(t *testing.T) {
 				if !reflect.DeepEqual(rm2, rm) {
 					t.Errorf("\ngot: %#v \nwant: %#v", rm2, rm)
 				}
+				if got, want := rm.ClientIP, "127.0.0.1"; got != want {
+					t.Errorf("got: %v, want: %v", got, want)
+				}
 			}),
 		)
 		mw := mr.Middleware()
@@ -141,4 +145,36 @@ func TestMetricsRecorder(t *testing.T) {
 			t.Errorf("\ngot: %#v \nwant: %#v", got, want)
 		}
 	})
+
+	t.Run("case=report", func(t *testing.T) {
+		var got *Report
+		mr := NewMetricsRecorder(
+			WithReportHookFunc(func(rep *Report) {
+				got = rep
+			}),
+		)
+		mr.RecordReport(&Report{Type: "csp-violation"})
+		mr.RecordReport(&Report{Type: "csp-violation"})
+		if got == nil || got.Type != "csp-violation" {
+			t.Errorf("got: %#v", got)
+		}
+		if got, want := mr.Metrics().ReportCount["csp-violation"], int64(2); got != want {
+			t.Errorf("got: %v, want: %v", got, want)
+		}
+
+		// Unknown types do not create new counters.
+		for i := 0; i < 5; i++ {
+			mr.RecordReport(&Report{Type: fmt.Sprintf("junk-%d", i)})
+		}
+		counts := mr.Metrics().ReportCount
+		if got, want := len(counts), 2; got != want {
+			t.Errorf("got: %v, want: %v", got, want)
+		}
+		if got, want := counts["other"], int64(5); got != want {
+			t.Errorf("got: %v, want: %v", got, want)
+		}
+		if got, want := got.Type, "junk-4"; got != want {
+			t.Errorf("got: %v, want: %v", got, want)
+		}
+	})
 }
@@ -12,6 +12,7 @@ type RequestMetrics struct {
 	Status                      int       `json:"status"`
 	UserAgent                   string    `json:"userAgent"`
 	Referer                     string    `json:"referer"`
+	ClientIP                    string    `json:"clientIP"`
 	GoroutinesCount             int64     `json:"goroutinesCount"`
 	RequestDurationNanoseconds  int64     `json:"requestDurationNanoseconds"`
 	RequestDurationMilliseconds int64     `json:"requestDurationMilliseconds"`
@@ -28,6 +29,7 @@ func (r *RequestMetrics) Clone() *RequestMetrics {
 		Status:                      r.Status,
 		UserAgent:                   r.UserAgent,
 		Referer:                     r.Referer,
+		ClientIP:                    r.ClientIP,
 		GoroutinesCount:             r.GoroutinesCount,
 		RequestDurationNanoseconds:  r.RequestDurationNanoseconds,
 		RequestDurationMilliseconds: r.RequestDurationMilliseconds,
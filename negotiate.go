@@ -0,0 +1,241 @@
+package umbrella
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// NegotiateConfig is the configuration for Negotiate.
+// Each list holds the offered values in order of preference, and an empty
+// list disables the negotiation of that dimension.
+type NegotiateConfig struct {
+	// MediaTypes is matched against the Accept header.
+	MediaTypes []string
+	// Languages is matched against the Accept-Language header.
+	Languages []string
+	// Charsets is matched against the Accept-Charset header.
+	Charsets []string
+	// Encodings is matched against the Accept-Encoding header. Requests
+	// without the header get the identity encoding.
+	Encodings []string
+}
+
+// Negotiation is the result of content negotiation.
+type Negotiation struct {
+	MediaType string
+	Language  string
+	Charset   string
+	Encoding  string
+}
+
+type negotiationKey struct{}
+
+// Negotiate is middleware that selects the best offered media type,
+// language, charset and encoding for the request following the rules of
+// RFC 9110 12.5, and stores them in the request context. The result is
+// available with GetNegotiation, and the Vary header lists the negotiated
+// request headers.
+// Returns 406 Not Acceptable status if no media type or charset is
+// acceptable, or if the identity encoding is excluded and no encoding is
+// acceptable. If no language is acceptable, the first one is selected.
+// It panics if a media type is invalid.
+func Negotiate(cfg NegotiateConfig) func(http.Handler) http.Handler {
+	mediaTypes := mustParseMediaRanges(cfg.MediaTypes)
+	var vary []string
+	for _, v := range []struct {
+		name   string
+		offers []string
+	}{
+		{"Accept", cfg.MediaTypes},
+		{"Accept-Language", cfg.Languages},
+		{"Accept-Charset", cfg.Charsets},
+		{"Accept-Encoding", cfg.Encodings},
+	} {
+		if len(v.offers) != 0 {
+			vary = append(vary, v.name)
+		}
+	}
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			addVary(w.Header(), vary...)
+			n := &Negotiation{}
+			if len(mediaTypes) != 0 {
+				n.MediaType = negotiateMediaType(r, mediaTypes)
+				if n.MediaType == "" {
+					http.Error(w, http.StatusText(http.StatusNotAcceptable), http.StatusNotAcceptable)
+					return
+				}
+			}
+			if len(cfg.Languages) != 0 {
+				if n.Language = NegotiateLanguage(r, cfg.Languages...); n.Language == "" {
+					n.Language = cfg.Languages[0]
+				}
+			}
+			if len(cfg.Charsets) != 0 {
+				if n.Charset = NegotiateCharset(r, cfg.Charsets...); n.Charset == "" {
+					http.Error(w, http.StatusText(http.StatusNotAcceptable), http.StatusNotAcceptable)
+					return
+				}
+			}
+			if len(cfg.Encodings) != 0 {
+				if n.Encoding = NegotiateEncoding(r, cfg.Encodings...); n.Encoding == "" {
+					http.Error(w, http.StatusText(http.StatusNotAcceptable), http.StatusNotAcceptable)
+					return
+				}
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), negotiationKey{}, n)))
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// GetNegotiation returns the result of Negotiate for the request.
+// If Negotiate has not been applied, all fields are empty.
+// The returned value must not be modified.
+func GetNegotiation(r *http.Request) *Negotiation {
+	if n, ok := r.Context().Value(negotiationKey{}).(*Negotiation); ok {
+		return n
+	}
+	return &Negotiation{}
+}
+
+// NegotiateMediaType returns the offered media type that is most preferred
+// by the Accept header, or an empty string if none is acceptable.
+// Offers that are not valid media types are ignored.
+func NegotiateMediaType(r *http.Request, offers ...string) string {
+	list := make([]*mediaRange, 0, len(offers))
+	for _, offer := range offers {
+		if m, err := parseMediaRange(offer); err == nil {
+			list = append(list, m)
+		}
+	}
+	return negotiateMediaType(r, list)
+}
+
+func negotiateMediaType(r *http.Request, offers []*mediaRange) string {
+	accept := parseAccept(r.Header.Get("Accept"))
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		if q := mediaTypeQuality(accept, offer); q > bestQ {
+			best, bestQ = offer.String(), q
+		}
+	}
+	return best
+}
+
+// NegotiateLanguage returns the offered language tag that is most preferred
+// by the Accept-Language header, or an empty string if none is acceptable.
+// Language ranges match tags with the same prefix, so "en" matches "en-US"
+// (RFC 4647 3.3.1).
+func NegotiateLanguage(r *http.Request, offers ...string) string {
+	return negotiate(r.Header.Values("Accept-Language"), offers, func(rng, offer string) (int, bool) {
+		if rng == "*" {
+			return 0, true
+		}
+		if strings.EqualFold(rng, offer) ||
+			(len(offer) > len(rng) && offer[len(rng)] == '-' && strings.EqualFold(rng, offer[:len(rng)])) {
+			return len(rng), true
+		}
+		return 0, false
+	}, "")
+}
+
+// NegotiateCharset returns the offered charset that is most preferred by the
+// Accept-Charset header, or an empty string if none is acceptable.
+func NegotiateCharset(r *http.Request, offers ...string) string {
+	return negotiate(r.Header.Values("Accept-Charset"), offers, matchQualityToken, "")
+}
+
+// NegotiateEncoding returns the offered content coding that is most
+// preferred by the Accept-Encoding header. If the header is missing or no
+// offer is acceptable, it returns "identity" unless the identity encoding is
+// excluded, in which case it returns an empty string.
+func NegotiateEncoding(r *http.Request, offers ...string) string {
+	values := r.Header.Values("Accept-Encoding")
+	if len(values) == 0 {
+		// Clients that do not send the header may not decode any coding.
+		return "identity"
+	}
+	if best := negotiate(values, offers, matchQualityToken, "identity"); best != "" {
+		return best
+	}
+	if negotiate(values, []string{"identity"}, matchQualityToken, "identity") != "" {
+		return "identity"
+	}
+	return ""
+}
+
+// matchQualityToken matches a case-insensitive token or "*".
+func matchQualityToken(rng, offer string) (int, bool) {
+	if rng == "*" {
+		return 0, true
+	}
+	return 1, strings.EqualFold(rng, offer)
+}
+
+// negotiate returns the offer with the highest quality in the header values,
+// preferring earlier offers. The quality of an offer is given by the most
+// specific range that matches it. If the header is missing, the first offer
+// is returned. implicit is an offer that is acceptable unless excluded.
+func negotiate(values, offers []string, match func(rng, offer string) (int, bool), implicit string) string {
+	if len(values) == 0 {
+		if len(offers) == 0 {
+			return ""
+		}
+		return offers[0]
+	}
+	var ranges []qualityValue
+	for _, v := range values {
+		ranges = append(ranges, parseQualityList(v)...)
+	}
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		q, specificity := 0.0, -1
+		if strings.EqualFold(offer, implicit) {
+			// The implicit offer is only excluded explicitly or by "*".
+			q = 1
+		}
+		for _, rng := range ranges {
+			if s, ok := match(rng.value, offer); ok && s > specificity {
+				q, specificity = rng.q, s
+			}
+		}
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
+
+// qualityValue is an element of a header with quality values, such as
+// "en;q=0.8".
+type qualityValue struct {
+	value string
+	q     float64
+}
+
+// parseQualityList parses a list of values with quality values.
+// Elements with an invalid quality value are ignored.
+func parseQualityList(value string) []qualityValue {
+	var list []qualityValue
+	for _, element := range splitHeaderList(value, ',') {
+		params := splitHeaderList(element, ';')
+		if len(params) == 0 {
+			continue
+		}
+		v := qualityValue{value: params[0], q: 1}
+		valid := true
+		for _, param := range params[1:] {
+			i := strings.IndexByte(param, '=')
+			if i < 0 || !strings.EqualFold(strings.TrimSpace(param[:i]), "q") {
+				continue
+			}
+			v.q, valid = parseQValue(strings.TrimSpace(param[i+1:]))
+		}
+		if valid {
+			list = append(list, v)
+		}
+	}
+	return list
+}
@@ -0,0 +1,159 @@
+package umbrella
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestNegotiate(t *testing.T) {
+	var got *Negotiation
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = GetNegotiation(r)
+		w.WriteHeader(http.StatusOK)
+	})
+	mw := Negotiate(NegotiateConfig{
+		MediaTypes: []string{"application/json", "application/xml", "text/csv"},
+		Languages:  []string{"en", "ja-JP"},
+		Charsets:   []string{"utf-8"},
+		Encodings:  []string{"br", "gzip"},
+	})
+
+	testCases := []struct {
+		name   string
+		header map[string]string
+		code   int
+		want   *Negotiation
+	}{
+		{
+			name: "default",
+			code: http.StatusOK,
+			want: &Negotiation{MediaType: "application/json", Language: "en", Charset: "utf-8", Encoding: "identity"},
+		},
+		{
+			name: "preferred",
+			header: map[string]string{
+				"Accept":          "text/csv, application/json;q=0.9",
+				"Accept-Language": "ja, en;q=0.5",
+				"Accept-Charset":  "UTF-8",
+				"Accept-Encoding": "gzip, br;q=0.5",
+			},
+			code: http.StatusOK,
+			want: &Negotiation{MediaType: "text/csv", Language: "ja-JP", Charset: "utf-8", Encoding: "gzip"},
+		},
+		{
+			name: "server preference",
+			header: map[string]string{
+				"Accept":          "application/*",
+				"Accept-Language": "*",
+				"Accept-Encoding": "*",
+			},
+			code: http.StatusOK,
+			want: &Negotiation{MediaType: "application/json", Language: "en", Charset: "utf-8", Encoding: "br"},
+		},
+		{
+			name: "excluded",
+			header: map[string]string{
+				"Accept":          "application/json;q=0, */*;q=0.1",
+				"Accept-Language": "fr",
+				"Accept-Encoding": "deflate",
+			},
+			code: http.StatusOK,
+			want: &Negotiation{MediaType: "application/xml", Language: "en", Charset: "utf-8", Encoding: "identity"},
+		},
+		{
+			name:   "media type not acceptable",
+			header: map[string]string{"Accept": "text/html"},
+			code:   http.StatusNotAcceptable,
+		},
+		{
+			name:   "charset not acceptable",
+			header: map[string]string{"Accept-Charset": "iso-8859-1"},
+			code:   http.StatusNotAcceptable,
+		},
+		{
+			name:   "encoding not acceptable",
+			header: map[string]string{"Accept-Encoding": "deflate, *;q=0"},
+			code:   http.StatusNotAcceptable,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run("case="+tc.name, func(t *testing.T) {
+			got = nil
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			for k, v := range tc.header {
+				r.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			mw(handler).ServeHTTP(w, r)
+			if got, want := w.Code, tc.code; got != want {
+				t.Errorf("got: %v, want: %v", got, want)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got: %#v, want: %#v", got, tc.want)
+			}
+			if got, want := w.Header().Values("Vary"), []string{"Accept", "Accept-Language", "Accept-Charset", "Accept-Encoding"}; !reflect.DeepEqual(got, want) {
+				t.Errorf("got: %v, want: %v", got, want)
+			}
+		})
+	}
+
+	t.Run("case=vary", func(t *testing.T) {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		w := httptest.NewRecorder()
+		w.Header().Set("Vary", "Origin, accept")
+		Negotiate(NegotiateConfig{MediaTypes: []string{"text/html"}, Languages: []string{"en"}})(handler).ServeHTTP(w, r)
+		if got, want := w.Header().Values("Vary"), []string{"Origin, accept", "Accept-Language"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("got: %v, want: %v", got, want)
+		}
+	})
+
+	t.Run("case=without middleware", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if got, want := GetNegotiation(r), (&Negotiation{}); !reflect.DeepEqual(got, want) {
+			t.Errorf("got: %#v, want: %#v", got, want)
+		}
+	})
+}
+
+func TestNegotiateHelpers(t *testing.T) {
+	testCases := []struct {
+		fn     func(r *http.Request, offers ...string) string
+		header string
+		value  string
+		offers []string
+		want   string
+	}{
+		{fn: NegotiateMediaType, header: "Accept", value: "text/html, application/xhtml+xml, application/xml;q=0.9, */*;q=0.8", offers: []string{"application/json", "application/xml"}, want: "application/xml"},
+		{fn: NegotiateMediaType, header: "Accept", value: "text/*;q=0.5, text/csv", offers: []string{"text/plain", "text/csv"}, want: "text/csv"},
+		{fn: NegotiateMediaType, header: "Accept", value: "application/json", offers: []string{"invalid", "application/json"}, want: "application/json"},
+		{fn: NegotiateMediaType, header: "Accept", value: "application/json", offers: []string{"text/csv"}, want: ""},
+		{fn: NegotiateLanguage, header: "Accept-Language", value: "en-GB, en;q=0.9, *;q=0.1", offers: []string{"ja", "en-US", "en-GB"}, want: "en-GB"},
+		{fn: NegotiateLanguage, header: "Accept-Language", value: "EN", offers: []string{"ja", "en-US"}, want: "en-US"},
+		{fn: NegotiateLanguage, header: "Accept-Language", value: "en-US", offers: []string{"en"}, want: ""},
+		{fn: NegotiateLanguage, header: "Accept-Language", value: "en, en-US;q=0", offers: []string{"en-US", "en-GB"}, want: "en-GB"},
+		{fn: NegotiateLanguage, header: "Accept-Language", value: "*, ja;q=0", offers: []string{"ja", "fr"}, want: "fr"},
+		{fn: NegotiateCharset, header: "Accept-Charset", value: "iso-8859-5, unicode-1-1;q=0.8", offers: []string{"utf-8", "unicode-1-1"}, want: "unicode-1-1"},
+		{fn: NegotiateEncoding, header: "Accept-Encoding", value: "gzip;q=1.0, identity; q=0.5, *;q=0", offers: []string{"br", "gzip"}, want: "gzip"},
+		{fn: NegotiateEncoding, header: "Accept-Encoding", value: "", offers: []string{"gzip"}, want: "identity"},
+		{fn: NegotiateEncoding, header: "Accept-Encoding", value: "br;q=0.5, gzip;q=abc", offers: []string{"gzip", "br"}, want: "br"},
+		{fn: NegotiateEncoding, header: "Accept-Encoding", value: "identity;q=0", offers: []string{"gzip"}, want: ""},
+	}
+	for _, tc := range testCases {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set(tc.header, tc.value)
+		if got := tc.fn(r, tc.offers...); got != tc.want {
+			t.Errorf("%s: %q %v: got: %v, want: %v", tc.header, tc.value, tc.offers, got, tc.want)
+		}
+	}
+
+	// Clients that do not send Accept-Encoding get the identity encoding.
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	if got, want := NegotiateEncoding(r, "gzip", "identity"), "identity"; got != want {
+		t.Errorf("got: %v, want: %v", got, want)
+	}
+}
@@ -0,0 +1,42 @@
+package umbrella
+
+import (
+	"log"
+	"net/http"
+	"time"
+)
+
+// OptimisticLock provides middleware that rejects PUT, PATCH and DELETE
+// requests made with an outdated copy of the resource, before the handler
+// runs. lookup returns the current ETag of the requested resource, or an
+// empty string if it does not exist.
+// Returns 428 Precondition Required status if the request has no If-Match
+// header, and 412 Precondition Failed status if the preconditions fail.
+func OptimisticLock(lookup func(r *http.Request) (string, error)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodPut, http.MethodPatch, http.MethodDelete:
+			default:
+				next.ServeHTTP(w, r)
+				return
+			}
+			if r.Header.Get("If-Match") == "" {
+				http.Error(w, http.StatusText(http.StatusPreconditionRequired), http.StatusPreconditionRequired)
+				return
+			}
+			etag, err := lookup(r)
+			if err != nil {
+				log.Printf("optimisticlock.error: %v", err)
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+			if code := CheckPreconditions(r, etag, time.Time{}); code != 0 {
+				http.Error(w, http.StatusText(code), code)
+				return
+			}
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
@@ -0,0 +1,50 @@
+package umbrella
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOptimisticLock(t *testing.T) {
+	versions := map[string]string{"/items/1": `"v2"`}
+	lookup := func(r *http.Request) (string, error) {
+		if r.URL.Path == "/items/error" {
+			return "", errors.New("error")
+		}
+		return versions[r.URL.Path], nil
+	}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mw := OptimisticLock(lookup)
+
+	testCases := []struct {
+		method  string
+		path    string
+		ifMatch string
+		code    int
+	}{
+		{method: http.MethodGet, path: "/items/1", code: http.StatusNoContent},
+		{method: http.MethodPost, path: "/items", code: http.StatusNoContent},
+		{method: http.MethodPut, path: "/items/1", code: http.StatusPreconditionRequired},
+		{method: http.MethodPut, path: "/items/1", ifMatch: `"v2"`, code: http.StatusNoContent},
+		{method: http.MethodPatch, path: "/items/1", ifMatch: `"v1"`, code: http.StatusPreconditionFailed},
+		{method: http.MethodPatch, path: "/items/1", ifMatch: `W/"v2"`, code: http.StatusPreconditionFailed},
+		{method: http.MethodDelete, path: "/items/1", ifMatch: "*", code: http.StatusNoContent},
+		{method: http.MethodDelete, path: "/items/2", ifMatch: "*", code: http.StatusPreconditionFailed},
+		{method: http.MethodDelete, path: "/items/error", ifMatch: "*", code: http.StatusInternalServerError},
+	}
+	for _, tc := range testCases {
+		r := httptest.NewRequest(tc.method, tc.path, nil)
+		if tc.ifMatch != "" {
+			r.Header.Set("If-Match", tc.ifMatch)
+		}
+		w := httptest.NewRecorder()
+		mw(handler).ServeHTTP(w, r)
+		if got, want := w.Code, tc.code; got != want {
+			t.Errorf("%s %s If-Match=%s: got: %v, want: %v", tc.method, tc.path, tc.ifMatch, got, want)
+		}
+	}
+}
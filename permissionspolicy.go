@@ -0,0 +1,262 @@
+package umbrella
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Allowlist members of the Permissions-Policy header. Origins such as
+// "https://example.com" can also be used, and an empty allowlist disables
+// the feature.
+const (
+	PermissionsSelf = "self"
+	PermissionsAll  = "*"
+)
+
+// permissionsPolicyFeatures is the list of known policy-controlled
+// features.
+var permissionsPolicyFeatures = map[string]bool{
+	"accelerometer":                   true,
+	"ambient-light-sensor":            true,
+	"attribution-reporting":           true,
+	"autoplay":                        true,
+	"bluetooth":                       true,
+	"browsing-topics":                 true,
+	"camera":                          true,
+	"clipboard-read":                  true,
+	"clipboard-write":                 true,
+	"compute-pressure":                true,
+	"cross-origin-isolated":           true,
+	"display-capture":                 true,
+	"document-domain":                 true,
+	"encrypted-media":                 true,
+	"execution-while-not-rendered":    true,
+	"execution-while-out-of-viewport": true,
+	"fullscreen":                      true,
+	"gamepad":                         true,
+	"geolocation":                     true,
+	"gyroscope":                       true,
+	"hid":                             true,
+	"identity-credentials-get":        true,
+	"idle-detection":                  true,
+	"keyboard-map":                    true,
+	"local-fonts":                     true,
+	"magnetometer":                    true,
+	"microphone":                      true,
+	"midi":                            true,
+	"otp-credentials":                 true,
+	"payment":                         true,
+	"picture-in-picture":              true,
+	"publickey-credentials-create":    true,
+	"publickey-credentials-get":       true,
+	"screen-wake-lock":                true,
+	"serial":                          true,
+	"speaker-selection":               true,
+	"storage-access":                  true,
+	"usb":                             true,
+	"web-share":                       true,
+	"window-management":               true,
+	"xr-spatial-tracking":             true,
+}
+
+// PermissionsPolicy builds the Permissions-Policy header.
+// Methods set the allowlist of a feature and return the policy so that
+// calls can be chained. Features are written in the order they were first
+// added.
+type PermissionsPolicy struct {
+	features []permissionsFeature
+}
+
+type permissionsFeature struct {
+	name      string
+	allowlist []string
+}
+
+// NewPermissionsPolicy creates and returns a new empty PermissionsPolicy.
+func NewPermissionsPolicy() *PermissionsPolicy {
+	return &PermissionsPolicy{}
+}
+
+// Allow adds the allowlist members to the feature. Without members, the
+// feature is disabled. It panics if the feature is unknown or a member is
+// not PermissionsSelf, PermissionsAll or an http or https origin.
+func (p *PermissionsPolicy) Allow(feature string, allowlist ...string) *PermissionsPolicy {
+	feature = strings.ToLower(feature)
+	if !permissionsPolicyFeatures[feature] {
+		panic(fmt.Sprintf("umbrella: unknown Permissions-Policy feature: %q", feature))
+	}
+	members := make([]string, len(allowlist))
+	for i, m := range allowlist {
+		members[i] = permissionsMember(m)
+	}
+	for i := range p.features {
+		if p.features[i].name == feature {
+			p.features[i].allowlist = append(p.features[i].allowlist, members...)
+			return p
+		}
+	}
+	p.features = append(p.features, permissionsFeature{name: feature, allowlist: members})
+	return p
+}
+
+// Deny disables the features in all documents.
+func (p *PermissionsPolicy) Deny(features ...string) *PermissionsPolicy {
+	for _, feature := range features {
+		p.Allow(feature)
+	}
+	return p
+}
+
+// Accelerometer adds the allowlist members to the accelerometer feature.
+func (p *PermissionsPolicy) Accelerometer(allowlist ...string) *PermissionsPolicy {
+	return p.Allow("accelerometer", allowlist...)
+}
+
+// Autoplay adds the allowlist members to the autoplay feature.
+func (p *PermissionsPolicy) Autoplay(allowlist ...string) *PermissionsPolicy {
+	return p.Allow("autoplay", allowlist...)
+}
+
+// Camera adds the allowlist members to the camera feature.
+func (p *PermissionsPolicy) Camera(allowlist ...string) *PermissionsPolicy {
+	return p.Allow("camera", allowlist...)
+}
+
+// DisplayCapture adds the allowlist members to the display-capture feature.
+func (p *PermissionsPolicy) DisplayCapture(allowlist ...string) *PermissionsPolicy {
+	return p.Allow("display-capture", allowlist...)
+}
+
+// EncryptedMedia adds the allowlist members to the encrypted-media feature.
+func (p *PermissionsPolicy) EncryptedMedia(allowlist ...string) *PermissionsPolicy {
+	return p.Allow("encrypted-media", allowlist...)
+}
+
+// Fullscreen adds the allowlist members to the fullscreen feature.
+func (p *PermissionsPolicy) Fullscreen(allowlist ...string) *PermissionsPolicy {
+	return p.Allow("fullscreen", allowlist...)
+}
+
+// Geolocation adds the allowlist members to the geolocation feature.
+func (p *PermissionsPolicy) Geolocation(allowlist ...string) *PermissionsPolicy {
+	return p.Allow("geolocation", allowlist...)
+}
+
+// Gyroscope adds the allowlist members to the gyroscope feature.
+func (p *PermissionsPolicy) Gyroscope(allowlist ...string) *PermissionsPolicy {
+	return p.Allow("gyroscope", allowlist...)
+}
+
+// Magnetometer adds the allowlist members to the magnetometer feature.
+func (p *PermissionsPolicy) Magnetometer(allowlist ...string) *PermissionsPolicy {
+	return p.Allow("magnetometer", allowlist...)
+}
+
+// Microphone adds the allowlist members to the microphone feature.
+func (p *PermissionsPolicy) Microphone(allowlist ...string) *PermissionsPolicy {
+	return p.Allow("microphone", allowlist...)
+}
+
+// Midi adds the allowlist members to the midi feature.
+func (p *PermissionsPolicy) Midi(allowlist ...string) *PermissionsPolicy {
+	return p.Allow("midi", allowlist...)
+}
+
+// Payment adds the allowlist members to the payment feature.
+func (p *PermissionsPolicy) Payment(allowlist ...string) *PermissionsPolicy {
+	return p.Allow("payment", allowlist...)
+}
+
+// PictureInPicture adds the allowlist members to the picture-in-picture
+// feature.
+func (p *PermissionsPolicy) PictureInPicture(allowlist ...string) *PermissionsPolicy {
+	return p.Allow("picture-in-picture", allowlist...)
+}
+
+// PublicKeyCredentialsGet adds the allowlist members to the
+// publickey-credentials-get feature.
+func (p *PermissionsPolicy) PublicKeyCredentialsGet(allowlist ...string) *PermissionsPolicy {
+	return p.Allow("publickey-credentials-get", allowlist...)
+}
+
+// ScreenWakeLock adds the allowlist members to the screen-wake-lock feature.
+func (p *PermissionsPolicy) ScreenWakeLock(allowlist ...string) *PermissionsPolicy {
+	return p.Allow("screen-wake-lock", allowlist...)
+}
+
+// USB adds the allowlist members to the usb feature.
+func (p *PermissionsPolicy) USB(allowlist ...string) *PermissionsPolicy {
+	return p.Allow("usb", allowlist...)
+}
+
+// WebShare adds the allowlist members to the web-share feature.
+func (p *PermissionsPolicy) WebShare(allowlist ...string) *PermissionsPolicy {
+	return p.Allow("web-share", allowlist...)
+}
+
+// XRSpatialTracking adds the allowlist members to the xr-spatial-tracking
+// feature.
+func (p *PermissionsPolicy) XRSpatialTracking(allowlist ...string) *PermissionsPolicy {
+	return p.Allow("xr-spatial-tracking", allowlist...)
+}
+
+// String returns the value of the header, which is a structured field
+// dictionary such as `camera=(), geolocation=(self "https://example.com")`.
+func (p *PermissionsPolicy) String() string {
+	list := make([]string, len(p.features))
+	for i, f := range p.features {
+		list[i] = f.name + "=" + permissionsAllowlist(f.allowlist)
+	}
+	return strings.Join(list, ", ")
+}
+
+// HeaderFunc returns a HeaderFunc that adds the policy.
+func (p *PermissionsPolicy) HeaderFunc() HeaderFunc {
+	return AddHeaderFunc("Permissions-Policy", p.String())
+}
+
+// Middleware returns middleware that adds the policy to the response.
+// Changes to the policy after calling Middleware are not reflected.
+func (p *PermissionsPolicy) Middleware() func(http.Handler) http.Handler {
+	return ResponseHeader(p.HeaderFunc())
+}
+
+// permissionsAllowlist returns the allowlist as a token or an inner list.
+func permissionsAllowlist(allowlist []string) string {
+	items := make([]string, 0, len(allowlist))
+	seen := map[string]bool{}
+	for _, m := range allowlist {
+		if m == PermissionsAll {
+			return PermissionsAll
+		}
+		if seen[m] {
+			continue
+		}
+		seen[m] = true
+		if m == PermissionsSelf {
+			items = append(items, m)
+		} else {
+			items = append(items, `"`+m+`"`)
+		}
+	}
+	return "(" + strings.Join(items, " ") + ")"
+}
+
+// permissionsMember returns the serialized allowlist member.
+func permissionsMember(m string) string {
+	switch strings.ToLower(m) {
+	case PermissionsSelf, "'self'":
+		return PermissionsSelf
+	case PermissionsAll:
+		return PermissionsAll
+	}
+	u, err := url.Parse(m)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" ||
+		u.User != nil || (u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.Fragment != "" ||
+		strings.ContainsAny(u.Host, "\"\\") {
+		panic(fmt.Sprintf("umbrella: invalid Permissions-Policy origin: %q", m))
+	}
+	return u.Scheme + "://" + strings.ToLower(u.Host)
+}
@@ -0,0 +1,83 @@
+package umbrella
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPermissionsPolicy(t *testing.T) {
+	t.Run("case=string", func(t *testing.T) {
+		testCases := []struct {
+			policy *PermissionsPolicy
+			want   string
+		}{
+			{
+				policy: NewPermissionsPolicy(),
+				want:   "",
+			},
+			{
+				policy: NewPermissionsPolicy().Camera().Microphone(),
+				want:   "camera=(), microphone=()",
+			},
+			{
+				policy: NewPermissionsPolicy().
+					Geolocation(PermissionsSelf, "https://Maps.Example.com/").
+					Payment(PermissionsSelf).
+					Geolocation("https://maps.example.com", "http://localhost:8080"),
+				want: `geolocation=(self "https://maps.example.com" "http://localhost:8080"), payment=(self)`,
+			},
+			{
+				policy: NewPermissionsPolicy().Fullscreen(PermissionsSelf, PermissionsAll).USB(),
+				want:   "fullscreen=*, usb=()",
+			},
+			{
+				policy: NewPermissionsPolicy().Deny("Camera", "usb").Allow("web-share", "'self'"),
+				want:   "camera=(), usb=(), web-share=(self)",
+			},
+		}
+		for _, tc := range testCases {
+			if got, want := tc.policy.String(), tc.want; got != want {
+				t.Errorf("got: %v, want: %v", got, want)
+			}
+		}
+	})
+
+	t.Run("case=invalid", func(t *testing.T) {
+		testCases := []func(){
+			func() { NewPermissionsPolicy().Allow("unknown") },
+			func() { NewPermissionsPolicy().Allow("") },
+			func() { NewPermissionsPolicy().Camera("example.com") },
+			func() { NewPermissionsPolicy().Camera("ftp://example.com") },
+			func() { NewPermissionsPolicy().Camera("https://example.com/path") },
+			func() { NewPermissionsPolicy().Camera("https://user@example.com") },
+			func() { NewPermissionsPolicy().Camera("https://example.com?q") },
+			func() { NewPermissionsPolicy().Camera(`https://exa"mple.com`) },
+		}
+		for i, fn := range testCases {
+			func() {
+				defer func() {
+					if recover() == nil {
+						t.Errorf("%d: expected panic", i)
+					}
+				}()
+				fn()
+			}()
+		}
+	})
+
+	t.Run("case=middleware", func(t *testing.T) {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})
+		p := NewPermissionsPolicy().Camera().Geolocation(PermissionsSelf)
+		mw := p.Middleware()
+		p.Payment()
+
+		w := httptest.NewRecorder()
+		mw(handler).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		if got, want := w.Header().Get("Permissions-Policy"), "camera=(), geolocation=(self)"; got != want {
+			t.Errorf("got: %v, want: %v", got, want)
+		}
+	})
+}
@@ -0,0 +1,274 @@
+package umbrella
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// proxyProtocolV2Signature is the signature of the binary header.
+var proxyProtocolV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+const (
+	// proxyProtocolV1MaxLength is the maximum length of the text header
+	// including CRLF.
+	proxyProtocolV1MaxLength = 107
+	// defaultProxyProtocolTimeout is the default time to read the header.
+	defaultProxyProtocolTimeout = 10 * time.Second
+)
+
+// ErrInvalidProxyHeader is returned when reading from a connection whose
+// PROXY protocol header is missing or malformed.
+var ErrInvalidProxyHeader = errors.New("umbrella: invalid PROXY protocol header")
+
+// ProxyProtocolConfig is the configuration for NewProxyProtocolListener.
+type ProxyProtocolConfig struct {
+	// TrustedSources is the list of networks of the load balancers that
+	// send the PROXY protocol header. Connections from other addresses are
+	// passed through as is. If it is empty, no source is trusted and the
+	// listener does nothing, as with RealIPConfig.TrustedProxies.
+	TrustedSources []*net.IPNet
+	// ReadHeaderTimeout is the time allowed to read the header.
+	// The default is 10 seconds.
+	ReadHeaderTimeout time.Duration
+}
+
+// NewProxyProtocolListener wraps the listener so that the RemoteAddr and
+// LocalAddr of accepted connections reflect the addresses given by the
+// PROXY protocol v1 (text) or v2 (binary) header sent by a load balancer.
+// Connections from trusted sources must start with the header. The header
+// is read on the first use of the connection, so a slow client does not
+// block Accept.
+func NewProxyProtocolListener(l net.Listener, cfg ProxyProtocolConfig) net.Listener {
+	if cfg.ReadHeaderTimeout <= 0 {
+		cfg.ReadHeaderTimeout = defaultProxyProtocolTimeout
+	}
+	return &proxyProtocolListener{Listener: l, cfg: cfg}
+}
+
+type proxyProtocolListener struct {
+	net.Listener
+	cfg ProxyProtocolConfig
+}
+
+// Accept implements the net.Listener.
+func (l *proxyProtocolListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	if !l.trusted(conn.RemoteAddr()) {
+		return conn, nil
+	}
+	return &proxyProtocolConn{
+		Conn:    conn,
+		r:       bufio.NewReaderSize(conn, proxyProtocolV1MaxLength),
+		timeout: l.cfg.ReadHeaderTimeout,
+	}, nil
+}
+
+func (l *proxyProtocolListener) trusted(addr net.Addr) bool {
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+	for _, n := range l.cfg.TrustedSources {
+		if n.Contains(tcpAddr.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+// proxyProtocolConn reads the PROXY protocol header on the first use.
+type proxyProtocolConn struct {
+	net.Conn
+	r       *bufio.Reader
+	timeout time.Duration
+
+	once       sync.Once
+	err        error
+	remoteAddr net.Addr
+	localAddr  net.Addr
+
+	mu           sync.Mutex
+	readDeadline time.Time
+}
+
+func (c *proxyProtocolConn) Read(p []byte) (int, error) {
+	c.once.Do(c.readHeader)
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.r.Read(p)
+}
+
+func (c *proxyProtocolConn) RemoteAddr() net.Addr {
+	c.once.Do(c.readHeader)
+	if c.remoteAddr != nil {
+		return c.remoteAddr
+	}
+	return c.Conn.RemoteAddr()
+}
+
+func (c *proxyProtocolConn) LocalAddr() net.Addr {
+	c.once.Do(c.readHeader)
+	if c.localAddr != nil {
+		return c.localAddr
+	}
+	return c.Conn.LocalAddr()
+}
+
+// SetDeadline and SetReadDeadline record the deadline so that it can be
+// restored after reading the header.
+func (c *proxyProtocolConn) SetDeadline(t time.Time) error {
+	c.mu.Lock()
+	c.readDeadline = t
+	c.mu.Unlock()
+	return c.Conn.SetDeadline(t)
+}
+
+func (c *proxyProtocolConn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	c.readDeadline = t
+	c.mu.Unlock()
+	return c.Conn.SetReadDeadline(t)
+}
+
+func (c *proxyProtocolConn) readHeader() {
+	_ = c.Conn.SetReadDeadline(time.Now().Add(c.timeout))
+	c.err = c.parseHeader()
+	c.mu.Lock()
+	_ = c.Conn.SetReadDeadline(c.readDeadline)
+	c.mu.Unlock()
+	if c.err != nil {
+		_ = c.Conn.Close()
+	}
+}
+
+func (c *proxyProtocolConn) parseHeader() error {
+	b, err := c.r.Peek(1)
+	if err != nil {
+		return err
+	}
+	switch b[0] {
+	case 'P':
+		return c.parseV1()
+	case proxyProtocolV2Signature[0]:
+		return c.parseV2()
+	}
+	return ErrInvalidProxyHeader
+}
+
+// parseV1 parses the text header, such as
+// "PROXY TCP4 192.0.2.1 192.0.2.2 56324 443\r\n".
+func (c *proxyProtocolConn) parseV1() error {
+	var line []byte
+	for {
+		b, err := c.r.ReadByte()
+		if err != nil {
+			return err
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+		if len(line) >= proxyProtocolV1MaxLength {
+			return ErrInvalidProxyHeader
+		}
+	}
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return ErrInvalidProxyHeader
+	}
+	fields := strings.Split(string(line[:len(line)-2]), " ")
+	if len(fields) < 2 || fields[0] != "PROXY" {
+		return ErrInvalidProxyHeader
+	}
+	switch fields[1] {
+	case "UNKNOWN":
+		// The connection was not proxied or the addresses are unknown.
+		return nil
+	case "TCP4", "TCP6":
+	default:
+		return ErrInvalidProxyHeader
+	}
+	if len(fields) != 6 {
+		return ErrInvalidProxyHeader
+	}
+	src, err := parseProxyProtocolV1Addr(fields[1], fields[2], fields[4])
+	if err != nil {
+		return err
+	}
+	dst, err := parseProxyProtocolV1Addr(fields[1], fields[3], fields[5])
+	if err != nil {
+		return err
+	}
+	c.remoteAddr, c.localAddr = src, dst
+	return nil
+}
+
+func parseProxyProtocolV1Addr(proto, host, port string) (*net.TCPAddr, error) {
+	ip := net.ParseIP(host)
+	if ip == nil || (proto == "TCP4") != (ip.To4() != nil) {
+		return nil, ErrInvalidProxyHeader
+	}
+	// Ports have no leading zeros or signs.
+	n, err := strconv.Atoi(port)
+	if err != nil || n < 0 || n > 65535 || strconv.Itoa(n) != port {
+		return nil, ErrInvalidProxyHeader
+	}
+	return &net.TCPAddr{IP: ip, Port: n}, nil
+}
+
+// parseV2 parses the binary header.
+func (c *proxyProtocolConn) parseV2() error {
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(c.r, header); err != nil {
+		return err
+	}
+	if !bytes.Equal(header[:12], proxyProtocolV2Signature) || header[12]>>4 != 2 {
+		return ErrInvalidProxyHeader
+	}
+	payload := make([]byte, binary.BigEndian.Uint16(header[14:16]))
+	if _, err := io.ReadFull(c.r, payload); err != nil {
+		return err
+	}
+	switch header[12] & 0x0f {
+	case 0x0:
+		// LOCAL: the connection was made by the load balancer itself.
+		return nil
+	case 0x1:
+		// PROXY
+	default:
+		return ErrInvalidProxyHeader
+	}
+	var size int
+	switch header[13] >> 4 {
+	case 0x1:
+		size = net.IPv4len
+	case 0x2:
+		size = net.IPv6len
+	default:
+		// AF_UNSPEC and AF_UNIX addresses are ignored.
+		return nil
+	}
+	if len(payload) < 2*size+4 {
+		return ErrInvalidProxyHeader
+	}
+	c.remoteAddr = &net.TCPAddr{
+		IP:   net.IP(append([]byte(nil), payload[:size]...)),
+		Port: int(binary.BigEndian.Uint16(payload[2*size:])),
+	}
+	c.localAddr = &net.TCPAddr{
+		IP:   net.IP(append([]byte(nil), payload[size:2*size]...)),
+		Port: int(binary.BigEndian.Uint16(payload[2*size+2:])),
+	}
+	return nil
+}
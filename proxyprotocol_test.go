@@ -0,0 +1,249 @@
+package umbrella
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+)
+
+func proxyProtocolV2Header(command byte, src, dst *net.TCPAddr) []byte {
+	var b bytes.Buffer
+	b.Write(proxyProtocolV2Signature)
+	b.WriteByte(0x20 | command)
+	var addrs bytes.Buffer
+	if src != nil {
+		family := byte(0x11)
+		srcIP, dstIP := src.IP.To4(), dst.IP.To4()
+		if srcIP == nil {
+			family, srcIP, dstIP = 0x21, src.IP.To16(), dst.IP.To16()
+		}
+		b.WriteByte(family)
+		addrs.Write(srcIP)
+		addrs.Write(dstIP)
+		_ = binary.Write(&addrs, binary.BigEndian, uint16(src.Port))
+		_ = binary.Write(&addrs, binary.BigEndian, uint16(dst.Port))
+		// TLVs are ignored.
+		addrs.Write([]byte{0x04, 0x00, 0x01, 0xff})
+	} else {
+		b.WriteByte(0x00)
+	}
+	_ = binary.Write(&b, binary.BigEndian, uint16(addrs.Len()))
+	b.Write(addrs.Bytes())
+	return b.Bytes()
+}
+
+func TestProxyProtocolListener(t *testing.T) {
+	local, _ := ParseCIDRs("127.0.0.0/8")
+	remote, _ := ParseCIDRs("192.0.2.0/24")
+
+	testCases := []struct {
+		name   string
+		cfg    ProxyProtocolConfig
+		header []byte
+		remote string
+		local  string
+		err    error
+	}{
+		{
+			name:   "v1 TCP4",
+			header: []byte("PROXY TCP4 192.0.2.1 192.0.2.2 56324 443\r\n"),
+			remote: "192.0.2.1:56324",
+			local:  "192.0.2.2:443",
+		},
+		{
+			name:   "v1 TCP6",
+			cfg:    ProxyProtocolConfig{TrustedSources: local},
+			header: []byte("PROXY TCP6 2001:db8::1 2001:db8::2 56324 443\r\n"),
+			remote: "[2001:db8::1]:56324",
+			local:  "[2001:db8::2]:443",
+		},
+		{
+			name:   "v1 UNKNOWN",
+			header: []byte("PROXY UNKNOWN ffff::1 ffff::2 1 2\r\n"),
+		},
+		{
+			name: "v2 TCP4",
+			header: proxyProtocolV2Header(0x1,
+				&net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 56324},
+				&net.TCPAddr{IP: net.ParseIP("192.0.2.2"), Port: 443},
+			),
+			remote: "192.0.2.1:56324",
+			local:  "192.0.2.2:443",
+		},
+		{
+			name: "v2 TCP6",
+			header: proxyProtocolV2Header(0x1,
+				&net.TCPAddr{IP: net.ParseIP("2001:db8::1"), Port: 56324},
+				&net.TCPAddr{IP: net.ParseIP("2001:db8::2"), Port: 443},
+			),
+			remote: "[2001:db8::1]:56324",
+			local:  "[2001:db8::2]:443",
+		},
+		{
+			name:   "v2 LOCAL",
+			header: proxyProtocolV2Header(0x0, nil, nil),
+		},
+		{
+			name:   "untrusted",
+			cfg:    ProxyProtocolConfig{TrustedSources: remote},
+			header: []byte("PROXY TCP4 192.0.2.1 192.0.2.2 56324 443\r\n"),
+		},
+		{
+			name:   "no trusted sources",
+			cfg:    ProxyProtocolConfig{TrustedSources: []*net.IPNet{}},
+			header: []byte("PROXY TCP4 192.0.2.1 192.0.2.2 56324 443\r\n"),
+		},
+		{
+			name:   "missing",
+			header: []byte("GET / HTTP/1.1\r\n"),
+			err:    ErrInvalidProxyHeader,
+		},
+		{
+			name:   "v1 invalid port",
+			header: []byte("PROXY TCP4 192.0.2.1 192.0.2.2 056324 443\r\n"),
+			err:    ErrInvalidProxyHeader,
+		},
+		{
+			name:   "v1 mismatched family",
+			header: []byte("PROXY TCP4 2001:db8::1 192.0.2.2 56324 443\r\n"),
+			err:    ErrInvalidProxyHeader,
+		},
+		{
+			name:   "v1 too long",
+			header: append([]byte("PROXY "), bytes.Repeat([]byte("x"), 120)...),
+			err:    ErrInvalidProxyHeader,
+		},
+		{
+			name:   "v2 invalid version",
+			header: append(append([]byte(nil), proxyProtocolV2Signature...), 0x11, 0x00, 0x00, 0x00),
+			err:    ErrInvalidProxyHeader,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run("case="+tc.name, func(t *testing.T) {
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			cfg := tc.cfg
+			if cfg.TrustedSources == nil {
+				cfg.TrustedSources = local
+			}
+			l := NewProxyProtocolListener(ln, cfg)
+			defer l.Close()
+
+			client, err := net.Dial("tcp", ln.Addr().String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer client.Close()
+			payload := append(append([]byte(nil), tc.header...), "payload"...)
+			if _, err := client.Write(payload); err != nil {
+				t.Fatal(err)
+			}
+
+			conn, err := l.Accept()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			remote, local := tc.remote, tc.local
+			if remote == "" {
+				remote, local = client.LocalAddr().String(), client.RemoteAddr().String()
+			}
+			if tc.err == nil {
+				if got, want := conn.RemoteAddr().String(), remote; got != want {
+					t.Errorf("got: %v, want: %v", got, want)
+				}
+				if got, want := conn.LocalAddr().String(), local; got != want {
+					t.Errorf("got: %v, want: %v", got, want)
+				}
+			}
+
+			want := []byte("payload")
+			if tc.name == "untrusted" || tc.name == "no trusted sources" {
+				want = payload
+			}
+			got := make([]byte, len(want))
+			_, err = io.ReadFull(conn, got)
+			if tc.err != nil {
+				if !errors.Is(err, tc.err) {
+					t.Errorf("got: %v, want: %v", err, tc.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("got: %q, want: %q", got, want)
+			}
+		})
+	}
+}
+
+func TestProxyProtocolListenerTimeout(t *testing.T) {
+	local, _ := ParseCIDRs("127.0.0.0/8")
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := NewProxyProtocolListener(ln, ProxyProtocolConfig{TrustedSources: local, ReadHeaderTimeout: 50 * time.Millisecond})
+	defer l.Close()
+
+	client, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	conn, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.Read(make([]byte, 1)); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("got: %v, want: %v", err, os.ErrDeadlineExceeded)
+	}
+}
+
+func TestProxyProtocolListenerHTTP(t *testing.T) {
+	local, _ := ParseCIDRs("127.0.0.0/8")
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	remoteAddr := make(chan string, 1)
+	srv := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			remoteAddr <- r.RemoteAddr
+			w.WriteHeader(http.StatusNoContent)
+		}),
+		ReadTimeout: time.Second,
+	}
+	go func() {
+		_ = srv.Serve(NewProxyProtocolListener(ln, ProxyProtocolConfig{TrustedSources: local}))
+	}()
+	defer srv.Close()
+
+	client, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	_, _ = client.Write([]byte("PROXY TCP4 192.0.2.1 192.0.2.2 56324 80\r\nGET / HTTP/1.1\r\nHost: example.com\r\n\r\n"))
+	select {
+	case got := <-remoteAddr:
+		if want := "192.0.2.1:56324"; got != want {
+			t.Errorf("got: %v, want: %v", got, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout")
+	}
+}
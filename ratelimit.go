@@ -20,11 +20,13 @@ func RateLimit(rl int) func(http.Handler) http.Handler {
 }
 
 // RateLimitPerIP provides middleware that limits the number of requests processed per second per IP.
+// The IP is taken from the ClientInfo of the request, so use RealIPWithConfig
+// before it to limit clients behind trusted proxies.
 func RateLimitPerIP(rl int) func(http.Handler) http.Handler {
 	var m sync.Map
 	return func(next http.Handler) http.Handler {
 		fn := func(w http.ResponseWriter, r *http.Request) {
-			ip := realIP(r)
+			ip := clientIP(r)
 			if v, ok := m.Load(ip); ok {
 				if l, ok := v.(*rate.Limiter); ok {
 					waitRateLimit(l, next, w, r)
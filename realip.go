@@ -1,6 +1,7 @@
 package umbrella
 
 import (
+	"fmt"
 	"net"
 	"net/http"
 	"strings"
@@ -26,21 +27,193 @@ func init() {
 	}
 }
 
-// RealIP is middleware that overwrites RemoteAddr of http.Request
-// with X-Forwarded-For or X-Real-IP header.
+// RealIP is middleware that stores the client address given by the
+// X-Forwarded-For or X-Real-IP header in the ClientInfo of the request.
 // Validation of the X-Forwarded-For header is done from right to left.
+// RealIP trusts the headers regardless of the peer that sent them, so any
+// client can spoof its address; use RealIPWithConfig to accept them only
+// from trusted proxies.
 func RealIP() func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		fn := func(w http.ResponseWriter, r *http.Request) {
-			if ip := realIP(r); ip != "" {
-				r.RemoteAddr = ip
+			info := newClientInfo(r)
+			if ip := net.ParseIP(realIP(r)); ip != nil {
+				info.IP = ip
 			}
-			next.ServeHTTP(w, r)
+			next.ServeHTTP(w, r.WithContext(withClientInfo(r.Context(), info)))
 		}
 		return http.HandlerFunc(fn)
 	}
 }
 
+// RealIPConfig is the configuration for RealIPWithConfig.
+type RealIPConfig struct {
+	// TrustedProxies is the list of networks of the proxies whose forwarding
+	// headers are honoured. If it is empty, the headers are ignored.
+	TrustedProxies []*net.IPNet
+	// Hops is the maximum number of addresses taken from the right of the
+	// forwarding headers. If it is zero, there is no limit.
+	Hops int
+	// Headers is the list of forwarding headers set by the proxies. The
+	// addresses of all the headers are joined in order, so the header of the
+	// outermost proxy comes first. If a header is malformed, the address of
+	// the peer is used. The trusted proxies must set or strip every header in
+	// the list, because a header sent by the client is taken as it is.
+	// The default is X-Forwarded-For.
+	Headers []string
+}
+
+// RealIPWithConfig is middleware that stores the client given by the
+// forwarding headers in the ClientInfo of the request, but only if the
+// request comes from a trusted proxy.
+// The addresses in the headers are walked from right to left, skipping those
+// of trusted proxies, and the first untrusted address is used. The scheme
+// and host of the original request are taken from the Forwarded header if
+// it is listed in Headers.
+func RealIPWithConfig(cfg RealIPConfig) func(http.Handler) http.Handler {
+	if len(cfg.Headers) == 0 {
+		cfg.Headers = []string{"X-Forwarded-For"}
+	}
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			info := cfg.resolve(r)
+			next.ServeHTTP(w, r.WithContext(withClientInfo(r.Context(), info)))
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// OriginalScheme returns the scheme of the request made by the client.
+// It is a shorthand for GetClientInfo(r).Scheme.
+func OriginalScheme(r *http.Request) string {
+	return GetClientInfo(r).Scheme
+}
+
+// OriginalHost returns the host of the request made by the client.
+// It is a shorthand for GetClientInfo(r).Host.
+func OriginalHost(r *http.Request) string {
+	return GetClientInfo(r).Host
+}
+
+// resolve returns the client of the request.
+func (cfg *RealIPConfig) resolve(r *http.Request) *ClientInfo {
+	info := newClientInfo(r)
+	if info.PeerIP == nil || !cfg.trusted(info.PeerIP) {
+		return info
+	}
+	var elements []ForwardedElement
+	for _, h := range cfg.Headers {
+		values := r.Header.Values(h)
+		if len(values) == 0 {
+			continue
+		}
+		if http.CanonicalHeaderKey(h) == "Forwarded" {
+			e, err := ParseForwarded(strings.Join(values, ","))
+			if err != nil {
+				// A malformed header cannot be trusted.
+				return info
+			}
+			elements = append(elements, e...)
+		} else {
+			for _, v := range strings.Split(strings.Join(values, ","), ",") {
+				elements = append(elements, ForwardedElement{For: strings.TrimSpace(v)})
+			}
+		}
+	}
+	for i, hops := len(elements)-1, 0; i >= 0; i, hops = i-1, hops+1 {
+		if cfg.Hops > 0 && hops >= cfg.Hops {
+			break
+		}
+		e := elements[i]
+		if isURIScheme(e.Proto) {
+			info.Scheme = e.Proto
+		}
+		if isHost(e.Host) {
+			info.Host = e.Host
+		}
+		ip := net.ParseIP(e.For)
+		if e.For != "" && ip == nil {
+			ip = e.IP()
+		}
+		if ip == nil {
+			// The client is obfuscated or the rest of the list cannot
+			// be trusted.
+			break
+		}
+		// The current address is a trusted proxy that forwarded ip.
+		info.Proxies = append(info.Proxies, info.IP)
+		info.IP = ip
+		if !cfg.trusted(ip) {
+			break
+		}
+	}
+	return info
+}
+
+func (cfg *RealIPConfig) trusted(ip net.IP) bool {
+	for _, n := range cfg.TrustedProxies {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseCIDRs parses the networks in CIDR notation, such as "192.0.2.0/24".
+// Addresses without a prefix length are treated as a single host.
+func ParseCIDRs(blocks ...string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(blocks))
+	for _, block := range blocks {
+		block = strings.TrimSpace(block)
+		if !strings.Contains(block, "/") {
+			ip := net.ParseIP(block)
+			if ip == nil {
+				return nil, fmt.Errorf("umbrella: invalid IP address: %q", block)
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(block)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// isURIScheme reports whether s is a valid URI scheme (RFC 3986 3.1).
+func isURIScheme(s string) bool {
+	if s == "" || !('a' <= s[0] && s[0] <= 'z') {
+		return false
+	}
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		if !('a' <= c && c <= 'z') && !('0' <= c && c <= '9') && c != '+' && c != '-' && c != '.' {
+			return false
+		}
+	}
+	return true
+}
+
+// isHost reports whether s can be used as the Host header.
+func isHost(s string) bool {
+	return s != "" && !strings.ContainsAny(s, "/\\@?# \t")
+}
+
+// remoteIP returns the address in RemoteAddr, which may not have a port.
+func remoteIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return net.ParseIP(host)
+}
+
 func realIP(r *http.Request) string {
 	headers := []string{
 		http.CanonicalHeaderKey("X-Forwarded-For"),
@@ -0,0 +1,68 @@
+package umbrella
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+// CloudflareRealIPConfig returns a RealIPConfig that takes the client
+// address from the CF-Connecting-IP header, trusting only peers in ranges.
+// Cloudflare publishes its ranges at https://www.cloudflare.com/ips/.
+func CloudflareRealIPConfig(ranges []*net.IPNet) RealIPConfig {
+	return vendorRealIPConfig("CF-Connecting-IP", ranges)
+}
+
+// FastlyRealIPConfig returns a RealIPConfig that takes the client address
+// from the Fastly-Client-IP header, trusting only peers in ranges.
+// Fastly publishes its ranges at https://api.fastly.com/public-ip-list.
+func FastlyRealIPConfig(ranges []*net.IPNet) RealIPConfig {
+	return vendorRealIPConfig("Fastly-Client-IP", ranges)
+}
+
+// AkamaiRealIPConfig returns a RealIPConfig that takes the client address
+// from the True-Client-IP header, trusting only peers in ranges.
+// Akamai provides its ranges to customers through Site Shield.
+func AkamaiRealIPConfig(ranges []*net.IPNet) RealIPConfig {
+	return vendorRealIPConfig("True-Client-IP", ranges)
+}
+
+// vendorRealIPConfig returns a RealIPConfig for a header that holds a single
+// address set by the CDN.
+func vendorRealIPConfig(header string, ranges []*net.IPNet) RealIPConfig {
+	return RealIPConfig{
+		TrustedProxies: ranges,
+		Hops:           1,
+		Headers:        []string{header},
+	}
+}
+
+// LoadCIDRFile reads a list of networks in CIDR notation from the file, one
+// per line. Empty lines and lines starting with # are ignored.
+func LoadCIDRFile(name string) ([]*net.IPNet, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var nets []*net.IPNet
+	s := bufio.NewScanner(f)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parsed, err := ParseCIDRs(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", name, n, err)
+		}
+		nets = append(nets, parsed...)
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return nets, nil
+}
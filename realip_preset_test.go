@@ -0,0 +1,118 @@
+package umbrella
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRealIPPresets(t *testing.T) {
+	ranges, _ := ParseCIDRs("173.245.48.0/20", "2400:cb00::/32")
+
+	testCases := []struct {
+		name   string
+		cfg    RealIPConfig
+		header string
+	}{
+		{name: "Cloudflare", cfg: CloudflareRealIPConfig(ranges), header: "CF-Connecting-IP"},
+		{name: "Fastly", cfg: FastlyRealIPConfig(ranges), header: "Fastly-Client-IP"},
+		{name: "Akamai", cfg: AkamaiRealIPConfig(ranges), header: "True-Client-IP"},
+	}
+	for _, tc := range testCases {
+		t.Run("case="+tc.name, func(t *testing.T) {
+			var got string
+			mw := RealIPWithConfig(tc.cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = GetClientInfo(r).IP.String()
+			}))
+
+			for _, c := range []struct {
+				remoteAddr string
+				header     map[string]string
+				want       string
+			}{
+				// The vendor header is honoured from the vendor ranges.
+				{
+					remoteAddr: "173.245.48.1:1234",
+					header:     map[string]string{tc.header: "100.100.100.100", "X-Forwarded-For": "101.101.101.101"},
+					want:       "100.100.100.100",
+				},
+				{
+					remoteAddr: "[2400:cb00::1]:1234",
+					header:     map[string]string{tc.header: "2001:db8::1"},
+					want:       "2001:db8::1",
+				},
+				// Only the address appended by the vendor is used.
+				{
+					remoteAddr: "173.245.48.1:1234",
+					header:     map[string]string{tc.header: "1.1.1.1, 100.100.100.100"},
+					want:       "100.100.100.100",
+				},
+				// Other peers cannot spoof the header.
+				{
+					remoteAddr: "198.51.100.1:1234",
+					header:     map[string]string{tc.header: "100.100.100.100"},
+					want:       "198.51.100.1",
+				},
+				// Other headers are ignored.
+				{
+					remoteAddr: "173.245.48.1:1234",
+					header:     map[string]string{"X-Forwarded-For": "100.100.100.100"},
+					want:       "173.245.48.1",
+				},
+			} {
+				r := httptest.NewRequest(http.MethodGet, "/", nil)
+				r.RemoteAddr = c.remoteAddr
+				for k, v := range c.header {
+					r.Header.Set(k, v)
+				}
+				mw.ServeHTTP(httptest.NewRecorder(), r)
+				if got != c.want {
+					t.Errorf("%s %v: got: %v, want: %v", c.remoteAddr, c.header, got, c.want)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadCIDRFile(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Run("case=ok", func(t *testing.T) {
+		name := filepath.Join(dir, "ok.txt")
+		content := "# Cloudflare\n173.245.48.0/20\n\n  2400:cb00::/32  \n192.0.2.1\n"
+		if err := os.WriteFile(name, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		nets, err := LoadCIDRFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, n := range nets {
+			got = append(got, n.String())
+		}
+		if got, want := strings.Join(got, ","), "173.245.48.0/20,2400:cb00::/32,192.0.2.1/32"; got != want {
+			t.Errorf("got: %v, want: %v", got, want)
+		}
+	})
+
+	t.Run("case=invalid", func(t *testing.T) {
+		name := filepath.Join(dir, "invalid.txt")
+		if err := os.WriteFile(name, []byte("173.245.48.0/20\ninvalid\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		_, err := LoadCIDRFile(name)
+		if err == nil || !strings.Contains(err.Error(), "invalid.txt:2") {
+			t.Errorf("got: %v, want: error at line 2", err)
+		}
+	})
+
+	t.Run("case=not exist", func(t *testing.T) {
+		if _, err := LoadCIDRFile(filepath.Join(dir, "none.txt")); !os.IsNotExist(err) {
+			t.Errorf("got: %v, want: not exist", err)
+		}
+	})
+}
@@ -2,13 +2,14 @@ package umbrella
 
 import (
 	"net/http"
+	"net/http/httptest"
 	"testing"
 )
 
 func TestRealIP(t *testing.T) {
 	t.Run("case=X-Forwarded-For", func(t *testing.T) {
 		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			if got, want := r.RemoteAddr, "100.100.100.100"; got != want {
+			if got, want := GetClientInfo(r).IP.String(), "100.100.100.100"; got != want {
 				t.Errorf("got: %v, want: %v", got, want)
 			}
 			w.WriteHeader(http.StatusOK)
@@ -29,7 +30,7 @@ func TestRealIP(t *testing.T) {
 
 	t.Run("case=X-Forwarded-For", func(t *testing.T) {
 		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			if got, want := r.RemoteAddr, "101.101.101.101"; got != want {
+			if got, want := GetClientInfo(r).IP.String(), "101.101.101.101"; got != want {
 				t.Errorf("got: %v, want: %v", got, want)
 			}
 			w.WriteHeader(http.StatusOK)
@@ -50,7 +51,7 @@ func TestRealIP(t *testing.T) {
 
 	t.Run("case=X-Real-IP", func(t *testing.T) {
 		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			if got, want := r.RemoteAddr, "100.100.100.100"; got != want {
+			if got, want := GetClientInfo(r).IP.String(), "100.100.100.100"; got != want {
 				t.Errorf("got: %v, want: %v", got, want)
 			}
 			w.WriteHeader(http.StatusOK)
@@ -86,3 +87,218 @@ func TestRealIP(t *testing.T) {
 		_ = resp.Body.Close()
 	})
 }
+
+func TestRealIPWithConfig(t *testing.T) {
+	proxies, err := ParseCIDRs("10.0.0.0/8", "203.0.113.7", "2001:db8::/32")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name       string
+		cfg        RealIPConfig
+		remoteAddr string
+		header     map[string]string
+		want       string
+		scheme     string
+		host       string
+	}{
+		{
+			name:       "untrusted peer",
+			cfg:        RealIPConfig{TrustedProxies: proxies},
+			remoteAddr: "198.51.100.1:1234",
+			header:     map[string]string{"X-Forwarded-For": "100.100.100.100"},
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "no trusted proxies",
+			cfg:        RealIPConfig{},
+			remoteAddr: "10.0.0.1:1234",
+			header:     map[string]string{"X-Forwarded-For": "100.100.100.100"},
+			want:       "10.0.0.1",
+		},
+		{
+			name:       "trusted peer",
+			cfg:        RealIPConfig{TrustedProxies: proxies},
+			remoteAddr: "10.0.0.1:1234",
+			header:     map[string]string{"X-Forwarded-For": "100.100.100.100"},
+			want:       "100.100.100.100",
+		},
+		{
+			name:       "spoofed hop",
+			cfg:        RealIPConfig{TrustedProxies: proxies},
+			remoteAddr: "10.0.0.1:1234",
+			header:     map[string]string{"X-Forwarded-For": "1.1.1.1, 100.100.100.100, 203.0.113.7"},
+			want:       "100.100.100.100",
+		},
+		{
+			name:       "all trusted",
+			cfg:        RealIPConfig{TrustedProxies: proxies},
+			remoteAddr: "10.0.0.1:1234",
+			header:     map[string]string{"X-Forwarded-For": "10.0.0.3, 10.0.0.2"},
+			want:       "10.0.0.3",
+		},
+		{
+			name:       "invalid hop",
+			cfg:        RealIPConfig{TrustedProxies: proxies},
+			remoteAddr: "10.0.0.1:1234",
+			header:     map[string]string{"X-Forwarded-For": "100.100.100.100, localhost, 10.0.0.2"},
+			want:       "10.0.0.2",
+		},
+		{
+			name:       "hops",
+			cfg:        RealIPConfig{TrustedProxies: proxies, Hops: 1},
+			remoteAddr: "10.0.0.1:1234",
+			header:     map[string]string{"X-Forwarded-For": "100.100.100.100, 10.0.0.2"},
+			want:       "10.0.0.2",
+		},
+		{
+			name:       "X-Real-IP",
+			cfg:        RealIPConfig{TrustedProxies: proxies, Headers: []string{"X-Real-IP"}},
+			remoteAddr: "[2001:db8::1]:1234",
+			header:     map[string]string{"X-Real-IP": "2001:db9::1"},
+			want:       "2001:db9::1",
+		},
+		{
+			name:       "headers",
+			cfg:        RealIPConfig{TrustedProxies: proxies, Headers: []string{"X-Client-IP"}},
+			remoteAddr: "10.0.0.1:1234",
+			header:     map[string]string{"X-Real-IP": "100.100.100.100", "X-Client-IP": "101.101.101.101"},
+			want:       "101.101.101.101",
+		},
+		{
+			name:       "Forwarded",
+			cfg:        RealIPConfig{TrustedProxies: proxies, Headers: []string{"Forwarded"}},
+			remoteAddr: "10.0.0.1:1234",
+			header: map[string]string{
+				"Forwarded":       `for=1.1.1.1;proto=http, for="[2001:db9::1]:4711";proto=https;host=example.com, for=10.0.0.2;proto=http;host=internal`,
+				"X-Forwarded-For": "100.100.100.100",
+			},
+			want:   "2001:db9::1",
+			scheme: "https",
+			host:   "example.com",
+		},
+		{
+			name:       "Forwarded obfuscated",
+			cfg:        RealIPConfig{TrustedProxies: proxies, Headers: []string{"Forwarded"}},
+			remoteAddr: "10.0.0.1:1234",
+			header:     map[string]string{"Forwarded": `for=_hidden;proto=https, for=10.0.0.2`},
+			want:       "10.0.0.2",
+			scheme:     "https",
+			host:       "example.com",
+		},
+		{
+			name:       "Forwarded malformed",
+			cfg:        RealIPConfig{TrustedProxies: proxies, Headers: []string{"Forwarded"}},
+			remoteAddr: "10.0.0.1:1234",
+			header: map[string]string{
+				"Forwarded":       `for="1.1.1.1, for=100.100.100.100;proto=https`,
+				"X-Forwarded-For": "101.101.101.101",
+			},
+			want:   "10.0.0.1",
+			scheme: "http",
+			host:   "example.com",
+		},
+		{
+			name:       "Forwarded malformed spoofed",
+			cfg:        RealIPConfig{TrustedProxies: proxies, Headers: []string{"Forwarded"}},
+			remoteAddr: "10.0.0.1:1234",
+			header: map[string]string{
+				"Forwarded":       `for="bad, for=203.0.113.9`,
+				"X-Forwarded-For": "6.6.6.6",
+			},
+			want: "10.0.0.1",
+		},
+		{
+			name:       "Forwarded untrusted peer",
+			cfg:        RealIPConfig{TrustedProxies: proxies, Headers: []string{"Forwarded"}},
+			remoteAddr: "198.51.100.1:1234",
+			header:     map[string]string{"Forwarded": `for=100.100.100.100;proto=https;host=evil.example`},
+			want:       "198.51.100.1",
+			scheme:     "http",
+			host:       "example.com",
+		},
+		{
+			name:       "joined headers",
+			cfg:        RealIPConfig{TrustedProxies: proxies, Headers: []string{"Forwarded", "X-Forwarded-For"}},
+			remoteAddr: "10.0.0.1:1234",
+			header: map[string]string{
+				"Forwarded":       `for=100.100.100.100`,
+				"X-Forwarded-For": "10.0.0.2",
+			},
+			want: "100.100.100.100",
+		},
+		{
+			name:       "mixed headers",
+			cfg:        RealIPConfig{TrustedProxies: proxies},
+			remoteAddr: "10.0.0.1:1234",
+			header: map[string]string{
+				"Forwarded":       `for=1.2.3.4;proto=https;host=evil.example`,
+				"X-Forwarded-For": "203.0.113.9",
+			},
+			want:   "203.0.113.9",
+			scheme: "http",
+			host:   "example.com",
+		},
+		{
+			name:       "mixed X-Real-IP",
+			cfg:        RealIPConfig{TrustedProxies: proxies, Headers: []string{"X-Real-IP"}},
+			remoteAddr: "10.0.0.1:1234",
+			header: map[string]string{
+				"X-Real-IP":       "203.0.113.9",
+				"X-Forwarded-For": "1.2.3.4",
+			},
+			want: "203.0.113.9",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run("case="+tc.name, func(t *testing.T) {
+			var got, remoteAddr, scheme, host string
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = GetClientInfo(r).IP.String()
+				remoteAddr = r.RemoteAddr
+				scheme = OriginalScheme(r)
+				host = OriginalHost(r)
+			})
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tc.remoteAddr
+			for k, v := range tc.header {
+				r.Header.Set(k, v)
+			}
+			RealIPWithConfig(tc.cfg)(handler).ServeHTTP(httptest.NewRecorder(), r)
+			if got != tc.want {
+				t.Errorf("got: %v, want: %v", got, tc.want)
+			}
+			if remoteAddr != tc.remoteAddr {
+				t.Errorf("got: %v, want: %v", remoteAddr, tc.remoteAddr)
+			}
+			if tc.scheme != "" && scheme != tc.scheme {
+				t.Errorf("got: %v, want: %v", scheme, tc.scheme)
+			}
+			if tc.host != "" && host != tc.host {
+				t.Errorf("got: %v, want: %v", host, tc.host)
+			}
+		})
+	}
+}
+
+func TestParseCIDRs(t *testing.T) {
+	nets, err := ParseCIDRs("10.0.0.0/8", " 192.0.2.1 ", "::1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"10.0.0.0/8", "192.0.2.1/32", "::1/128"}
+	if got, want := len(nets), len(want); got != want {
+		t.Fatalf("got: %v, want: %v", got, want)
+	}
+	for i := range nets {
+		if got, want := nets[i].String(), want[i]; got != want {
+			t.Errorf("got: %v, want: %v", got, want)
+		}
+	}
+	for _, block := range []string{"localhost", "10.0.0.0/33"} {
+		if _, err := ParseCIDRs(block); err == nil {
+			t.Errorf("%s: got: nil, want: error", block)
+		}
+	}
+}
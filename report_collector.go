@@ -0,0 +1,220 @@
+package umbrella
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// reportDedupMaxEntries limits the memory used for deduplication.
+const reportDedupMaxEntries = 10000
+
+// legacyCSPReportFields maps the fields of application/csp-report to the
+// names used by the Reporting API.
+var legacyCSPReportFields = map[string]string{
+	"document-uri":        "documentURL",
+	"referrer":            "referrer",
+	"blocked-uri":         "blockedURL",
+	"effective-directive": "effectiveDirective",
+	"violated-directive":  "effectiveDirective",
+	"original-policy":     "originalPolicy",
+	"disposition":         "disposition",
+	"status-code":         "statusCode",
+	"source-file":         "sourceFile",
+	"line-number":         "lineNumber",
+	"column-number":       "columnNumber",
+	"script-sample":       "sample",
+}
+
+// reportTypes is the list of report types known to be sent by browsers.
+// Reports of other types are counted as "other" by MetricsRecorder, so that
+// clients cannot create an unbounded number of counters.
+var reportTypes = map[string]bool{
+	"coep":                         true,
+	"coop":                         true,
+	"crash":                        true,
+	"csp-hash":                     true,
+	"csp-violation":                true,
+	"deprecation":                  true,
+	"document-policy-violation":    true,
+	"integrity-violation":          true,
+	"intervention":                 true,
+	"network-error":                true,
+	"permissions-policy-violation": true,
+}
+
+// Report is a report sent by a browser, such as a CSP violation.
+type Report struct {
+	// Type is the type of the report, such as "csp-violation", "coep",
+	// "deprecation" or "network-error".
+	Type string `json:"type"`
+	// URL is the URL of the document that generated the report.
+	URL string `json:"url"`
+	// UserAgent is the User-Agent of the browser.
+	UserAgent string `json:"user_agent"`
+	// Age is the number of milliseconds between the generation and the
+	// delivery of the report.
+	Age int64 `json:"age"`
+	// Body holds the fields of the report, named as in the Reporting API.
+	Body map[string]interface{} `json:"body"`
+}
+
+// ReportCollector receives reports sent by browsers with the report-uri
+// directive (application/csp-report) and the Reporting API
+// (application/reports+json), and forwards them to a function or a
+// MetricsRecorder.
+type ReportCollector struct {
+	opts *reportCollectorOptions
+
+	mu   sync.Mutex
+	seen map[[sha256.Size]byte]time.Time
+}
+
+// NewReportCollector creates and returns a new ReportCollector.
+func NewReportCollector(opts ...ReportCollectorOption) *ReportCollector {
+	return &ReportCollector{
+		opts: newReportCollectorOptions(opts...),
+		seen: make(map[[sha256.Size]byte]time.Time),
+	}
+}
+
+// Handler receives reports in the request body.
+// It accepts POST requests and returns 204 No Content status, 413 Request
+// Entity Too Large status if the body is too large, 415 Unsupported Media
+// Type status for other formats, and 400 Bad Request status for malformed
+// reports.
+func (c *ReportCollector) Handler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		mediaType = ""
+	}
+	var parse func(raw []byte, userAgent string) ([]*Report, error)
+	switch mediaType {
+	case "application/reports+json":
+		parse = parseReports
+	case "application/csp-report", "application/json":
+		parse = parseLegacyCSPReport
+	default:
+		http.Error(w, http.StatusText(http.StatusUnsupportedMediaType), http.StatusUnsupportedMediaType)
+		return
+	}
+
+	raw, err := io.ReadAll(io.LimitReader(r.Body, c.opts.maxBodySize+1))
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	if int64(len(raw)) > c.opts.maxBodySize {
+		http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+		return
+	}
+	reports, err := parse(raw, r.UserAgent())
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	now := time.Now()
+	for _, rep := range reports {
+		if c.duplicate(rep, now) {
+			continue
+		}
+		if c.opts.fn != nil {
+			c.opts.fn(rep)
+		}
+		if c.opts.mr != nil {
+			c.opts.mr.RecordReport(rep)
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// duplicate reports whether an identical report was received within the
+// deduplication window, and records the report.
+func (c *ReportCollector) duplicate(rep *Report, now time.Time) bool {
+	if c.opts.dedupWindow <= 0 {
+		return false
+	}
+	// The age and the User-Agent are not part of the identity.
+	body, err := json.Marshal(rep.Body)
+	if err != nil {
+		log.Printf("report.error: %v", err)
+		return false
+	}
+	key := sha256.Sum256(append([]byte(rep.Type+"\x00"+rep.URL+"\x00"), body...))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if t, ok := c.seen[key]; ok && now.Sub(t) < c.opts.dedupWindow {
+		return true
+	}
+	if len(c.seen) >= reportDedupMaxEntries {
+		for k, t := range c.seen {
+			if now.Sub(t) >= c.opts.dedupWindow {
+				delete(c.seen, k)
+			}
+		}
+		if len(c.seen) >= reportDedupMaxEntries {
+			c.seen = make(map[[sha256.Size]byte]time.Time)
+		}
+	}
+	c.seen[key] = now
+	return false
+}
+
+// parseReports parses a list of reports of the Reporting API.
+func parseReports(raw []byte, userAgent string) ([]*Report, error) {
+	var reports []*Report
+	if err := json.Unmarshal(raw, &reports); err != nil {
+		return nil, err
+	}
+	if len(reports) == 0 {
+		return nil, errors.New("umbrella: no reports")
+	}
+	for _, rep := range reports {
+		if rep == nil || rep.Type == "" || rep.Body == nil {
+			return nil, errors.New("umbrella: invalid report")
+		}
+		if rep.UserAgent == "" {
+			rep.UserAgent = userAgent
+		}
+	}
+	return reports, nil
+}
+
+// parseLegacyCSPReport parses a report sent with the report-uri directive.
+func parseLegacyCSPReport(raw []byte, userAgent string) ([]*Report, error) {
+	var v struct {
+		Report map[string]interface{} `json:"csp-report"`
+	}
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return nil, err
+	}
+	if v.Report == nil {
+		return nil, errors.New("umbrella: invalid report")
+	}
+	rep := &Report{Type: "csp-violation", UserAgent: userAgent, Body: make(map[string]interface{})}
+	for k, value := range v.Report {
+		name, ok := legacyCSPReportFields[k]
+		if !ok {
+			name = k
+		}
+		// effective-directive takes precedence over violated-directive.
+		if _, ok := rep.Body[name]; ok && k == "violated-directive" {
+			continue
+		}
+		rep.Body[name] = value
+	}
+	rep.URL, _ = rep.Body["documentURL"].(string)
+	return []*Report{rep}, nil
+}
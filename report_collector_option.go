@@ -0,0 +1,59 @@
+package umbrella
+
+import "time"
+
+// ReportCollectorOption configures a ReportCollector.
+type ReportCollectorOption func(o *reportCollectorOptions)
+
+type reportCollectorOptions struct {
+	fn          func(*Report)
+	mr          *MetricsRecorder
+	maxBodySize int64
+	dedupWindow time.Duration
+}
+
+func newReportCollectorOptions(opts ...ReportCollectorOption) *reportCollectorOptions {
+	o := &reportCollectorOptions{
+		maxBodySize: 64 << 10,
+		dedupWindow: time.Minute,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithReportFunc sets the function to be called for each received report.
+func WithReportFunc(fn func(*Report)) ReportCollectorOption {
+	return func(o *reportCollectorOptions) {
+		o.fn = fn
+	}
+}
+
+// WithReportMetricsRecorder forwards received reports to the
+// MetricsRecorder, which counts them and calls its report hook function.
+func WithReportMetricsRecorder(mr *MetricsRecorder) ReportCollectorOption {
+	return func(o *reportCollectorOptions) {
+		o.mr = mr
+	}
+}
+
+// WithReportMaxBodySize sets the maximum size of a request body.
+// The default is 64 KiB.
+func WithReportMaxBodySize(n int64) ReportCollectorOption {
+	return func(o *reportCollectorOptions) {
+		if n > 0 {
+			o.maxBodySize = n
+		}
+	}
+}
+
+// WithReportDedupWindow sets the duration during which identical reports
+// are dropped. The default is one minute, and zero disables deduplication.
+func WithReportDedupWindow(d time.Duration) ReportCollectorOption {
+	return func(o *reportCollectorOptions) {
+		if d >= 0 {
+			o.dedupWindow = d
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package umbrella
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReportCollector(t *testing.T) {
+	const legacy = `{"csp-report":{"document-uri":"https://example.com/","violated-directive":"script-src-elem","effective-directive":"script-src-elem","blocked-uri":"https://evil.example/x.js","original-policy":"script-src 'self'"}}`
+	const reports = `[
+		{"type":"csp-violation","age":10,"url":"https://example.com/","user_agent":"Mozilla/5.0","body":{"documentURL":"https://example.com/","blockedURL":"inline","effectiveDirective":"script-src-elem"}},
+		{"type":"coep","age":20,"url":"https://example.com/","body":{"type":"corp","blockedURL":"https://cdn.example/a.png"}},
+		{"type":"deprecation","age":30,"url":"https://example.com/","body":{"id":"websql","message":"WebSQL is deprecated"}},
+		{"type":"network-error","age":40,"url":"https://example.com/","body":{"type":"tcp.timed_out","elapsed_time":1000}},
+		{"type":"junk","age":50,"url":"https://example.com/","body":{}}
+	]`
+
+	t.Run("case=ok", func(t *testing.T) {
+		var got []*Report
+		mr := NewMetricsRecorder()
+		c := NewReportCollector(
+			WithReportFunc(func(rep *Report) {
+				got = append(got, rep)
+			}),
+			WithReportMetricsRecorder(mr),
+		)
+
+		r := httptest.NewRequest(http.MethodPost, "/reports", strings.NewReader(legacy))
+		r.Header.Set("Content-Type", "application/csp-report")
+		r.Header.Set("User-Agent", "test")
+		w := httptest.NewRecorder()
+		c.Handler(w, r)
+		if got, want := w.Code, http.StatusNoContent; got != want {
+			t.Errorf("got: %v, want: %v", got, want)
+		}
+
+		r = httptest.NewRequest(http.MethodPost, "/reports", strings.NewReader(reports))
+		r.Header.Set("Content-Type", "application/reports+json")
+		r.Header.Set("User-Agent", "test")
+		w = httptest.NewRecorder()
+		c.Handler(w, r)
+		if got, want := w.Code, http.StatusNoContent; got != want {
+			t.Errorf("got: %v, want: %v", got, want)
+		}
+
+		if got, want := len(got), 6; got != want {
+			t.Fatalf("got: %v, want: %v", got, want)
+		}
+		rep := got[0]
+		if rep.Type != "csp-violation" || rep.URL != "https://example.com/" || rep.UserAgent != "test" ||
+			rep.Body["blockedURL"] != "https://evil.example/x.js" || rep.Body["effectiveDirective"] != "script-src-elem" {
+			t.Errorf("got: %#v", rep)
+		}
+		if got, want := got[1].UserAgent, "Mozilla/5.0"; got != want {
+			t.Errorf("got: %v, want: %v", got, want)
+		}
+		if got, want := got[2].UserAgent, "test"; got != want {
+			t.Errorf("got: %v, want: %v", got, want)
+		}
+		if got, want := got[4].Type, "network-error"; got != want {
+			t.Errorf("got: %v, want: %v", got, want)
+		}
+		counts := mr.Metrics().ReportCount
+		for typ, want := range map[string]int64{"csp-violation": 2, "coep": 1, "deprecation": 1, "network-error": 1, "other": 1} {
+			if got := counts[typ]; got != want {
+				t.Errorf("%s: got: %v, want: %v", typ, got, want)
+			}
+		}
+		if got, want := len(counts), 5; got != want {
+			t.Errorf("got: %v, want: %v", got, want)
+		}
+	})
+
+	t.Run("case=dedup", func(t *testing.T) {
+		count := 0
+		c := NewReportCollector(
+			WithReportFunc(func(rep *Report) {
+				count++
+			}),
+			WithReportDedupWindow(50*time.Millisecond),
+		)
+		send := func(body string) {
+			r := httptest.NewRequest(http.MethodPost, "/reports", strings.NewReader(body))
+			r.Header.Set("Content-Type", "application/reports+json")
+			c.Handler(httptest.NewRecorder(), r)
+		}
+		send(`[{"type":"deprecation","age":1,"url":"/a","body":{"id":"a"}},{"type":"deprecation","age":2,"url":"/a","body":{"id":"a"}}]`)
+		send(`[{"type":"deprecation","age":3,"url":"/a","body":{"id":"a"}}]`)
+		send(`[{"type":"deprecation","age":3,"url":"/b","body":{"id":"a"}}]`)
+		if got, want := count, 2; got != want {
+			t.Errorf("got: %v, want: %v", got, want)
+		}
+		time.Sleep(60 * time.Millisecond)
+		send(`[{"type":"deprecation","age":4,"url":"/a","body":{"id":"a"}}]`)
+		if got, want := count, 3; got != want {
+			t.Errorf("got: %v, want: %v", got, want)
+		}
+	})
+
+	t.Run("case=invalid", func(t *testing.T) {
+		c := NewReportCollector(WithReportMaxBodySize(int64(len(reports))))
+		testCases := []struct {
+			method      string
+			contentType string
+			body        string
+			code        int
+		}{
+			{method: http.MethodGet, code: http.StatusMethodNotAllowed},
+			{method: http.MethodPost, contentType: "text/plain", body: legacy, code: http.StatusUnsupportedMediaType},
+			{method: http.MethodPost, contentType: "application/reports+json", body: reports + " ", code: http.StatusRequestEntityTooLarge},
+			{method: http.MethodPost, contentType: "application/reports+json", body: `{"type":"coep"}`, code: http.StatusBadRequest},
+			{method: http.MethodPost, contentType: "application/reports+json", body: `[]`, code: http.StatusBadRequest},
+			{method: http.MethodPost, contentType: "application/reports+json", body: `[{"type":"coep"}]`, code: http.StatusBadRequest},
+			{method: http.MethodPost, contentType: "application/reports+json", body: `[{"body":{}}]`, code: http.StatusBadRequest},
+			{method: http.MethodPost, contentType: "application/csp-report", body: `{"report":{}}`, code: http.StatusBadRequest},
+			{method: http.MethodPost, contentType: "application/json", body: `{`, code: http.StatusBadRequest},
+			{method: http.MethodPost, contentType: "application/json", body: legacy, code: http.StatusNoContent},
+		}
+		for _, tc := range testCases {
+			r := httptest.NewRequest(tc.method, "/reports", strings.NewReader(tc.body))
+			if tc.contentType != "" {
+				r.Header.Set("Content-Type", tc.contentType)
+			}
+			w := httptest.NewRecorder()
+			c.Handler(w, r)
+			if got, want := w.Code, tc.code; got != want {
+				t.Errorf("%s %s %s: got: %v, want: %v", tc.method, tc.contentType, tc.body, got, want)
+			}
+		}
+	})
+}
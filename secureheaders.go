@@ -0,0 +1,181 @@
+package umbrella
+
+import (
+	"context"
+	"net/http"
+)
+
+// RemoveSecureHeader is the value of a SecureHeadersConfig field that
+// removes the header, including one set by previous middleware.
+const RemoveSecureHeader = "-"
+
+// SecureHeadersConfig is the configuration for SecureHeaders.
+// Each field is the value of a response header. Empty fields leave the
+// header unchanged, and fields set to RemoveSecureHeader remove it.
+type SecureHeadersConfig struct {
+	// FrameOptions is the X-Frame-Options header.
+	FrameOptions string
+	// ContentTypeOptions is the X-Content-Type-Options header.
+	ContentTypeOptions string
+	// XSSProtection is the deprecated X-XSS-Protection header.
+	XSSProtection string
+	// StrictTransportSecurity is the Strict-Transport-Security header.
+	StrictTransportSecurity string
+	// ReferrerPolicy is the Referrer-Policy header.
+	ReferrerPolicy string
+	// PermissionsPolicy is the Permissions-Policy header.
+	PermissionsPolicy string
+	// CrossOriginOpenerPolicy is the Cross-Origin-Opener-Policy header.
+	CrossOriginOpenerPolicy string
+	// CrossOriginEmbedderPolicy is the Cross-Origin-Embedder-Policy header.
+	CrossOriginEmbedderPolicy string
+	// CrossOriginResourcePolicy is the Cross-Origin-Resource-Policy header.
+	CrossOriginResourcePolicy string
+	// PermittedCrossDomainPolicies is the
+	// X-Permitted-Cross-Domain-Policies header.
+	PermittedCrossDomainPolicies string
+}
+
+type secureHeadersKey struct{}
+
+// StrictSecureHeadersConfig returns a configuration that isolates the
+// document from other origins and disables powerful features. The
+// deprecated X-XSS-Protection header is removed.
+func StrictSecureHeadersConfig() SecureHeadersConfig {
+	return SecureHeadersConfig{
+		FrameOptions:                 "DENY",
+		ContentTypeOptions:           "nosniff",
+		XSSProtection:                RemoveSecureHeader,
+		StrictTransportSecurity:      "max-age=63072000; includeSubDomains",
+		ReferrerPolicy:               "no-referrer",
+		PermissionsPolicy:            "camera=(), microphone=(), geolocation=(), payment=(), usb=()",
+		CrossOriginOpenerPolicy:      "same-origin",
+		CrossOriginEmbedderPolicy:    "require-corp",
+		CrossOriginResourcePolicy:    "same-origin",
+		PermittedCrossDomainPolicies: "none",
+	}
+}
+
+// BalancedSecureHeadersConfig returns a configuration that suits most web
+// applications, allowing popups and resources from the same site.
+// X-XSS-Protection is disabled, because the XSS auditor of old browsers
+// can be abused.
+func BalancedSecureHeadersConfig() SecureHeadersConfig {
+	return SecureHeadersConfig{
+		FrameOptions:                 "SAMEORIGIN",
+		ContentTypeOptions:           "nosniff",
+		XSSProtection:                "0",
+		StrictTransportSecurity:      "max-age=31536000",
+		ReferrerPolicy:               "strict-origin-when-cross-origin",
+		PermissionsPolicy:            "camera=(), microphone=(), geolocation=()",
+		CrossOriginOpenerPolicy:      "same-origin-allow-popups",
+		CrossOriginResourcePolicy:    "same-site",
+		PermittedCrossDomainPolicies: "none",
+	}
+}
+
+// LegacySecureHeadersConfig returns a configuration with the headers that
+// are understood by old browsers, in the same way as Clickjacking,
+// ContentSniffing, XSSFiltering and HSTS.
+func LegacySecureHeadersConfig() SecureHeadersConfig {
+	return SecureHeadersConfig{
+		FrameOptions:                 "SAMEORIGIN",
+		ContentTypeOptions:           "nosniff",
+		XSSProtection:                "1; mode=block",
+		StrictTransportSecurity:      "max-age=31536000",
+		ReferrerPolicy:               "strict-origin-when-cross-origin",
+		PermittedCrossDomainPolicies: "none",
+	}
+}
+
+// SecureHeaders is middleware that adds the security headers of the
+// configuration to the response. Strict-Transport-Security is only added
+// to responses to HTTPS requests, as required by RFC 6797. The headers can
+// be changed for a route with OverrideSecureHeaders. Headers of empty fields
+// are left as set by previous middleware.
+func SecureHeaders(cfg SecureHeadersConfig) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			cfg := cfg
+			cfg.write(w, r, nil)
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), secureHeadersKey{}, &cfg)))
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// OverrideSecureHeaders is middleware that changes the configuration of
+// SecureHeaders applied before it, and updates the headers of the response.
+// Headers of fields that fn clears are removed.
+// Without SecureHeaders, fn receives an empty configuration.
+func OverrideSecureHeaders(fn func(cfg *SecureHeadersConfig)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		h := func(w http.ResponseWriter, r *http.Request) {
+			var cfg SecureHeadersConfig
+			if v, ok := r.Context().Value(secureHeadersKey{}).(*SecureHeadersConfig); ok {
+				cfg = *v
+			}
+			prev := cfg
+			fn(&cfg)
+			cfg.write(w, r, &prev)
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), secureHeadersKey{}, &cfg)))
+		}
+		return http.HandlerFunc(h)
+	}
+}
+
+// SecureHeadersHeaderFunc returns a HeaderFunc that adds the security
+// headers of the configuration, including Strict-Transport-Security
+// regardless of the scheme of the request.
+func SecureHeadersHeaderFunc(cfg SecureHeadersConfig) HeaderFunc {
+	return func(header http.Header) {
+		cfg.apply(header, nil, true)
+	}
+}
+
+// write adds the headers to the response to the request.
+func (cfg *SecureHeadersConfig) write(w http.ResponseWriter, r *http.Request, prev *SecureHeadersConfig) {
+	cfg.apply(w.Header(), prev, GetClientInfo(r).Scheme == "https")
+}
+
+// apply sets the headers of non-empty fields, and removes the headers of
+// fields set to RemoveSecureHeader or that are set in prev but empty in cfg.
+func (cfg *SecureHeadersConfig) apply(header http.Header, prev *SecureHeadersConfig, hsts bool) {
+	var prevHeaders []secureHeader
+	if prev != nil {
+		prevHeaders = prev.headers()
+	}
+	for i, h := range cfg.headers() {
+		if h.name == "Strict-Transport-Security" && !hsts {
+			continue
+		}
+		switch {
+		case h.value == RemoveSecureHeader:
+			header.Del(h.name)
+		case h.value != "":
+			header.Set(h.name, h.value)
+		case prevHeaders != nil && prevHeaders[i].value != "":
+			header.Del(h.name)
+		}
+	}
+}
+
+type secureHeader struct {
+	name  string
+	value string
+}
+
+func (cfg *SecureHeadersConfig) headers() []secureHeader {
+	return []secureHeader{
+		{"X-Frame-Options", cfg.FrameOptions},
+		{"X-Content-Type-Options", cfg.ContentTypeOptions},
+		{"X-XSS-Protection", cfg.XSSProtection},
+		{"Strict-Transport-Security", cfg.StrictTransportSecurity},
+		{"Referrer-Policy", cfg.ReferrerPolicy},
+		{"Permissions-Policy", cfg.PermissionsPolicy},
+		{"Cross-Origin-Opener-Policy", cfg.CrossOriginOpenerPolicy},
+		{"Cross-Origin-Embedder-Policy", cfg.CrossOriginEmbedderPolicy},
+		{"Cross-Origin-Resource-Policy", cfg.CrossOriginResourcePolicy},
+		{"X-Permitted-Cross-Domain-Policies", cfg.PermittedCrossDomainPolicies},
+	}
+}
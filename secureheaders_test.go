@@ -0,0 +1,180 @@
+package umbrella
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSecureHeaders(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	t.Run("case=presets", func(t *testing.T) {
+		testCases := []struct {
+			name string
+			cfg  SecureHeadersConfig
+			want map[string]string
+		}{
+			{
+				name: "strict",
+				cfg:  StrictSecureHeadersConfig(),
+				want: map[string]string{
+					"X-Frame-Options":                   "DENY",
+					"X-Content-Type-Options":            "nosniff",
+					"X-XSS-Protection":                  "",
+					"Strict-Transport-Security":         "max-age=63072000; includeSubDomains",
+					"Referrer-Policy":                   "no-referrer",
+					"Permissions-Policy":                "camera=(), microphone=(), geolocation=(), payment=(), usb=()",
+					"Cross-Origin-Opener-Policy":        "same-origin",
+					"Cross-Origin-Embedder-Policy":      "require-corp",
+					"Cross-Origin-Resource-Policy":      "same-origin",
+					"X-Permitted-Cross-Domain-Policies": "none",
+				},
+			},
+			{
+				name: "balanced",
+				cfg:  BalancedSecureHeadersConfig(),
+				want: map[string]string{
+					"X-Frame-Options":              "SAMEORIGIN",
+					"X-XSS-Protection":             "0",
+					"Cross-Origin-Opener-Policy":   "same-origin-allow-popups",
+					"Cross-Origin-Embedder-Policy": "",
+					"Cross-Origin-Resource-Policy": "same-site",
+				},
+			},
+			{
+				name: "legacy",
+				cfg:  LegacySecureHeadersConfig(),
+				want: map[string]string{
+					"X-Frame-Options":            "SAMEORIGIN",
+					"X-XSS-Protection":           "1; mode=block",
+					"Permissions-Policy":         "",
+					"Cross-Origin-Opener-Policy": "",
+				},
+			},
+		}
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				w := httptest.NewRecorder()
+				r := httptest.NewRequest(http.MethodGet, "https://example.com/", nil)
+				SecureHeaders(tc.cfg)(handler).ServeHTTP(w, r)
+				for name, want := range tc.want {
+					if got := w.Header().Get(name); got != want {
+						t.Errorf("%s: got: %v, want: %v", name, got, want)
+					}
+				}
+			})
+		}
+	})
+
+	t.Run("case=override", func(t *testing.T) {
+		var cfg *SecureHeadersConfig
+		inner := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			cfg, _ = r.Context().Value(secureHeadersKey{}).(*SecureHeadersConfig)
+			w.WriteHeader(http.StatusOK)
+		})
+		embed := OverrideSecureHeaders(func(cfg *SecureHeadersConfig) {
+			cfg.FrameOptions = ""
+			cfg.CrossOriginResourcePolicy = "cross-origin"
+		})
+		mw := SecureHeaders(StrictSecureHeadersConfig())
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/embed", nil)
+		mw(embed(inner)).ServeHTTP(w, r)
+		if got, want := w.Header().Get("X-Frame-Options"), ""; got != want {
+			t.Errorf("got: %v, want: %v", got, want)
+		}
+		if got, want := w.Header().Get("Cross-Origin-Resource-Policy"), "cross-origin"; got != want {
+			t.Errorf("got: %v, want: %v", got, want)
+		}
+		if got, want := w.Header().Get("Referrer-Policy"), "no-referrer"; got != want {
+			t.Errorf("got: %v, want: %v", got, want)
+		}
+		if cfg == nil || cfg.CrossOriginResourcePolicy != "cross-origin" {
+			t.Errorf("got: %#v", cfg)
+		}
+
+		// Other routes are not affected by the override.
+		w = httptest.NewRecorder()
+		mw(inner).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		if got, want := w.Header().Get("X-Frame-Options"), "DENY"; got != want {
+			t.Errorf("got: %v, want: %v", got, want)
+		}
+		if got, want := w.Header().Get("Cross-Origin-Resource-Policy"), "same-origin"; got != want {
+			t.Errorf("got: %v, want: %v", got, want)
+		}
+	})
+
+	t.Run("case=previous-middleware", func(t *testing.T) {
+		policy := NewPermissionsPolicy().Camera()
+		hsts := HSTSConfig{MaxAge: 63072000, IncludeSubDomains: true}
+		cfg := LegacySecureHeadersConfig()
+		cfg.StrictTransportSecurity = ""
+		h := policy.Middleware()(HSTSWithConfig(hsts)(SecureHeaders(cfg)(handler)))
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "https://example.com/", nil)
+		h.ServeHTTP(w, r)
+		if got, want := w.Header().Get("Permissions-Policy"), policy.String(); got != want {
+			t.Errorf("got: %v, want: %v", got, want)
+		}
+		if got, want := w.Header().Get("Strict-Transport-Security"), hsts.String(); got != want {
+			t.Errorf("got: %v, want: %v", got, want)
+		}
+		if got, want := w.Header().Get("X-Frame-Options"), "SAMEORIGIN"; got != want {
+			t.Errorf("got: %v, want: %v", got, want)
+		}
+	})
+
+	t.Run("case=override-previous-middleware", func(t *testing.T) {
+		policy := NewPermissionsPolicy().Camera()
+		embed := OverrideSecureHeaders(func(cfg *SecureHeadersConfig) {
+			cfg.FrameOptions = ""
+		})
+		h := policy.Middleware()(SecureHeaders(LegacySecureHeadersConfig())(embed(handler)))
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "https://example.com/", nil)
+		h.ServeHTTP(w, r)
+		if got, want := w.Header().Get("Permissions-Policy"), policy.String(); got != want {
+			t.Errorf("got: %v, want: %v", got, want)
+		}
+		if got, want := w.Header().Get("X-Frame-Options"), ""; got != want {
+			t.Errorf("got: %v, want: %v", got, want)
+		}
+	})
+
+	t.Run("case=strict-removes", func(t *testing.T) {
+		h := XSSFiltering("")(SecureHeaders(StrictSecureHeadersConfig())(handler))
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "https://example.com/", nil)
+		h.ServeHTTP(w, r)
+		if got, want := w.Header().Get("X-XSS-Protection"), ""; got != want {
+			t.Errorf("got: %v, want: %v", got, want)
+		}
+	})
+
+	t.Run("case=http", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+		SecureHeaders(StrictSecureHeadersConfig())(handler).ServeHTTP(w, r)
+		if got, want := w.Header().Get("Strict-Transport-Security"), ""; got != want {
+			t.Errorf("got: %v, want: %v", got, want)
+		}
+		if got, want := w.Header().Get("X-Frame-Options"), "DENY"; got != want {
+			t.Errorf("got: %v, want: %v", got, want)
+		}
+	})
+
+	t.Run("case=header-func", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		ResponseHeader(SecureHeadersHeaderFunc(LegacySecureHeadersConfig()))(handler).ServeHTTP(w, r)
+		if got, want := w.Header().Get("X-XSS-Protection"), "1; mode=block"; got != want {
+			t.Errorf("got: %v, want: %v", got, want)
+		}
+	})
+}
@@ -1,6 +1,9 @@
 package umbrella
 
 import (
+	"context"
+	"errors"
+	"log"
 	"net/http"
 	"net/http/httptest"
 	"sync"
@@ -13,15 +16,18 @@ import (
 // It uses singleflight for caching to prevent thundering-herd and cache-stampede.
 // If this middleware is requested at the same time, it executes the handler
 // only once and shares the execution result with all requests.
-func Stampede(d time.Duration) func(http.Handler) http.Handler {
-	type cache struct {
-		time   time.Time
-		code   int
-		header http.Header
-		body   []byte
-	}
-	var sm sync.Map
-	var group singleflight.Group
+// Responses with a 5xx status are not cached. Expired responses can still be
+// served with the WithStaleWhileRevalidate and WithStaleIfError options, and
+// the storage can be replaced with the WithCacheStore option.
+func Stampede(d time.Duration, opts ...CacheOption) func(http.Handler) http.Handler {
+	mw, _ := NewStampede(d, opts...)
+	return mw
+}
+
+// NewStampede creates a Stampede middleware and returns it together with a
+// CacheController that removes responses from its cache.
+func NewStampede(d time.Duration, opts ...CacheOption) (func(http.Handler) http.Handler, *CacheController) {
+	s := &stampede{d: d, opts: newCacheOptions(opts...)}
 	return func(next http.Handler) http.Handler {
 		fn := func(w http.ResponseWriter, r *http.Request) {
 			// If the method is not GET or HEAD, call the handler.
@@ -29,37 +35,116 @@ func Stampede(d time.Duration) func(http.Handler) http.Handler {
 				next.ServeHTTP(w, r)
 				return
 			}
+			s.serveHTTP(next, w, r)
+		}
+		return http.HandlerFunc(fn)
+	}, NewCacheController(s.opts.store)
+}
 
-			path := r.URL.String()
-			v, _, _ := group.Do(path, func() (interface{}, error) {
-				if v, ok := sm.Load(path); ok {
-					if c, ok := v.(*cache); ok {
-						if c.time.After(time.Now()) {
-							return c, nil
-						}
-					}
-					sm.Delete(path)
-				}
-				// Use ResponseRecorder to record the results.
-				rec := httptest.NewRecorder()
-				next.ServeHTTP(rec, r)
-				c := &cache{
-					time:   time.Now().Add(d),
-					code:   rec.Code,
-					header: rec.Header(),
-					body:   rec.Body.Bytes(),
-				}
-				sm.Store(path, c)
-				return c, nil
-			})
+type stampede struct {
+	d          time.Duration
+	opts       *cacheOptions
+	group      singleflight.Group
+	refreshing sync.Map
+}
 
-			c := v.(*cache)
-			for k, v := range c.header {
-				w.Header()[k] = v
+type stampedeResult struct {
+	res   *CachedResponse
+	panic interface{}
+}
+
+func (s *stampede) serveHTTP(next http.Handler, w http.ResponseWriter, r *http.Request) {
+	path := r.URL.String()
+	now := time.Now()
+	var stale *CachedResponse
+	if c, err := s.opts.store.Get(path); err == nil {
+		switch {
+		case c.Expires.After(now):
+			writeCachedResponse(w, c)
+			return
+		case c.Expires.Add(s.opts.staleWhileRevalidate).After(now):
+			// Only one goroutine refreshes the response in the background.
+			if _, loaded := s.refreshing.LoadOrStore(path, struct{}{}); !loaded {
+				go s.refresh(next, r.Clone(context.Background()))
 			}
-			w.WriteHeader(c.code)
-			_, _ = w.Write(c.body)
+			writeCachedResponse(w, c)
+			return
+		case c.Expires.Add(s.opts.staleIfError).After(now):
+			stale = c
 		}
-		return http.HandlerFunc(fn)
+	} else if !errors.Is(err, ErrCacheMiss) {
+		log.Printf("stampede.error: %v", err)
+	}
+
+	v, _, _ := s.group.Do(path, func() (interface{}, error) {
+		return s.fetch(next, r), nil
+	})
+	res := v.(*stampedeResult)
+	if res.failed() {
+		if stale != nil {
+			writeCachedResponse(w, stale)
+			return
+		}
+		if res.panic != nil {
+			panic(res.panic)
+		}
+	}
+	writeCachedResponse(w, res.res)
+}
+
+func (s *stampede) refresh(next http.Handler, r *http.Request) {
+	path := r.URL.String()
+	defer s.refreshing.Delete(path)
+	v, _, _ := s.group.Do(path, func() (interface{}, error) {
+		return s.fetch(next, r), nil
+	})
+	if res := v.(*stampedeResult); res.panic != nil {
+		log.Printf("stampede.error: %v", res.panic)
+	}
+}
+
+// fetch executes the handler and caches the response unless it failed.
+func (s *stampede) fetch(next http.Handler, r *http.Request) (res *stampedeResult) {
+	res = new(stampedeResult)
+	defer func() {
+		if p := recover(); p != nil {
+			res.panic = p
+		}
+	}()
+	// Use ResponseRecorder to record the results.
+	rec := httptest.NewRecorder()
+	next.ServeHTTP(rec, r)
+	res.res = &CachedResponse{
+		Tags:       extractCacheTags(rec.Header()),
+		StatusCode: rec.Code,
+		Header:     rec.Header(),
+		Body:       rec.Body.Bytes(),
+		Expires:    time.Now().Add(s.d),
+	}
+	if !res.failed() {
+		// Keep the response while it can still be served as stale.
+		ttl := s.d + s.opts.staleWhileRevalidate
+		if s.d+s.opts.staleIfError > ttl {
+			ttl = s.d + s.opts.staleIfError
+		}
+		if ttl > 0 {
+			if err := s.opts.store.Set(r.URL.String(), res.res, ttl); err != nil {
+				log.Printf("stampede.error: %v", err)
+			}
+		}
+	}
+	return res
+}
+
+func (res *stampedeResult) failed() bool {
+	return res.panic != nil || res.res.StatusCode >= http.StatusInternalServerError
+}
+
+// writeCachedResponse writes the stored response to w.
+func writeCachedResponse(w http.ResponseWriter, c *CachedResponse) {
+	for k, v := range c.Header {
+		w.Header()[k] = v
 	}
+	w.WriteHeader(c.StatusCode)
+	_, _ = w.Write(c.Body)
 }
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -112,4 +113,108 @@ func TestStampede(t *testing.T) {
 			t.Fatalf("should not be equal: %s, %s", results[0], results[1])
 		}
 	})
+
+	t.Run("stale-while-revalidate", func(t *testing.T) {
+		var calls int32
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			n := atomic.AddInt32(&calls, 1)
+			w.WriteHeader(http.StatusOK)
+			fmt.Fprintf(w, "%d", n)
+		})
+
+		teardown := setup(Stampede(time.Millisecond*50, WithStaleWhileRevalidate(time.Second))(handler))
+		defer teardown()
+
+		get := func() string {
+			resp, err := httpClient.Get(httpServer.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			raw, _ := io.ReadAll(resp.Body)
+			return string(raw)
+		}
+
+		if got, want := get(), "1"; got != want {
+			t.Fatalf("got: %v, want: %v", got, want)
+		}
+		time.Sleep(time.Millisecond * 60)
+		// The stale response is served while it is being refreshed.
+		if got, want := get(), "1"; got != want {
+			t.Fatalf("got: %v, want: %v", got, want)
+		}
+		for i := 0; atomic.LoadInt32(&calls) < 2 && i < 100; i++ {
+			time.Sleep(time.Millisecond * 5)
+		}
+		if got, want := get(), "2"; got != want {
+			t.Fatalf("got: %v, want: %v", got, want)
+		}
+	})
+
+	t.Run("stale-if-error", func(t *testing.T) {
+		for _, failure := range []string{"status", "panic"} {
+			var calls int32
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if atomic.AddInt32(&calls, 1) > 1 {
+					if failure == "panic" {
+						panic("stampede test")
+					}
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+				w.WriteHeader(http.StatusOK)
+				fmt.Fprint(w, "ok")
+			})
+
+			teardown := setup(Stampede(time.Millisecond*10, WithStaleIfError(time.Second))(handler))
+
+			for i := 0; i < 2; i++ {
+				resp, err := httpClient.Get(httpServer.URL)
+				if err != nil {
+					t.Fatal(err)
+				}
+				raw, _ := io.ReadAll(resp.Body)
+				_ = resp.Body.Close()
+				if got, want := resp.StatusCode, http.StatusOK; got != want {
+					t.Errorf("%s: got: %v, want: %v", failure, got, want)
+				}
+				if got, want := string(raw), "ok"; got != want {
+					t.Errorf("%s: got: %v, want: %v", failure, got, want)
+				}
+				time.Sleep(time.Millisecond * 20)
+			}
+			if got, want := atomic.LoadInt32(&calls), int32(2); got != want {
+				t.Errorf("%s: got: %v, want: %v", failure, got, want)
+			}
+			teardown()
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		var calls int32
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(&calls, 1) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		})
+
+		teardown := setup(Stampede(time.Second * 5)(handler))
+		defer teardown()
+
+		for _, want := range []int{http.StatusServiceUnavailable, http.StatusOK, http.StatusOK} {
+			resp, err := httpClient.Get(httpServer.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_ = resp.Body.Close()
+			if got := resp.StatusCode; got != want {
+				t.Errorf("got: %v, want: %v", got, want)
+			}
+		}
+		if got, want := atomic.LoadInt32(&calls), int32(2); got != want {
+			t.Errorf("got: %v, want: %v", got, want)
+		}
+	})
 }